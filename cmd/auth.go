@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/gws/internal/gcloud"
+	"github.com/bakito/gws/internal/types"
+)
+
+var (
+	authAccount string
+	authAll     bool
+)
+
+// authCmd represents the auth command.
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage the Google OAuth token",
+}
+
+// authLoginCmd represents the auth login command.
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Login with a fresh OAuth token",
+	RunE: func(*cobra.Command, []string) error {
+		cfg, err := authConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return gcloud.ForceLogin(ctx, cfg, authAccount)
+	},
+}
+
+// authStatusCmd represents the auth status command.
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of the stored OAuth tokens",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := authConfig()
+		if err != nil {
+			return err
+		}
+
+		infos, err := gcloud.GetTokenInfo(cfg)
+		if err != nil {
+			return err
+		}
+
+		for i, info := range infos {
+			if i > 0 {
+				cmd.Println()
+			}
+			account := info.Account
+			if account == "" {
+				account = "unknown"
+			}
+			if info.Default {
+				account += " (default)"
+			}
+			expiry := info.Expiry.Local().Format(time.RFC822)
+			if info.Expired() {
+				expiry += " (expired)"
+			}
+			cmd.Printf("Account:       %s\n", account)
+			cmd.Printf("Expiry:        %s\n", expiry)
+			cmd.Printf("Refresh Token: %t\n", info.HasRefreshToken)
+			cmd.Printf("Scopes:        %s\n", strings.Join(info.Scopes, "\n               "))
+		}
+		return nil
+	},
+}
+
+// authLogoutCmd represents the auth logout command.
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Delete the stored OAuth token",
+	RunE: func(*cobra.Command, []string) error {
+		cfg, err := authConfig()
+		if err != nil {
+			return err
+		}
+		if authAll {
+			return gcloud.LogoutAll(cfg)
+		}
+		return gcloud.Logout(cfg, authAccount)
+	},
+}
+
+// authRevokeCmd represents the auth revoke command.
+var authRevokeCmd = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke the stored OAuth token at Google and delete it",
+	RunE: func(*cobra.Command, []string) error {
+		cfg, err := authConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if authAll {
+			return gcloud.RevokeAll(ctx, cfg)
+		}
+		return gcloud.Revoke(ctx, cfg, authAccount)
+	},
+}
+
+// authConfig returns the config with the stored token, the auth commands do not depend on the contexts.
+// A missing config file or current context is ignored, all other config errors are returned.
+func authConfig() (*types.Config, error) {
+	cfg, err := loadConfig()
+	if err != nil && !errors.Is(err, types.ErrConfigNotFound) && !errors.Is(err, types.ErrContextNotDefined) {
+		return nil, err
+	}
+	return cfg, cfg.LoadToken()
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authLoginCmd, authStatusCmd, authLogoutCmd, authRevokeCmd)
+
+	for _, c := range []*cobra.Command{authLoginCmd, authLogoutCmd, authRevokeCmd} {
+		c.Flags().StringVar(&authAccount, "account", "",
+			"The Google account (email), the default account is used if omitted")
+	}
+	for _, c := range []*cobra.Command{authLogoutCmd, authRevokeCmd} {
+		c.Flags().BoolVar(&authAll, "all", false, "Apply to the tokens of all accounts")
+		c.MarkFlagsMutuallyExclusive("account", "all")
+	}
+}
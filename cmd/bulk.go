@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/gws/internal/gcloud"
+	"github.com/bakito/gws/internal/types"
+)
+
+var (
+	flagAll   bool
+	flagGroup string
+)
+
+func addBulkFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().
+		BoolVar(&flagAll, "all", false, "Run the command for the workstations of all contexts with a gcloud config")
+	cmd.PersistentFlags().
+		StringVar(&flagGroup, "group", "", "Run the command for the workstations of the contexts of the given group")
+	cmd.MarkFlagsMutuallyExclusive("all", "group")
+}
+
+func isBulk() bool {
+	return flagAll || flagGroup != ""
+}
+
+// runBulk runs the action concurrently for all contexts or the contexts of a group.
+func runBulk(cmd *cobra.Command, action gcloud.Action) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	contexts, err := bulkContexts(cfg)
+	if err != nil {
+		return err
+	}
+	return runBulkAction(cmd, cfg, contexts, action)
+}
+
+// bulkContexts returns the contexts with a workstation or the contexts of the group.
+// The contexts of a group are used as is, so a context without gcloud config fails as if named explicitly.
+func bulkContexts(cfg *types.Config) ([]string, error) {
+	if flagGroup != "" {
+		return cfg.GroupContexts(flagGroup)
+	}
+	return slices.DeleteFunc(cfg.ContextNames(), func(name string) bool {
+		return cfg.Contexts[name].GCloud == nil
+	}), nil
+}
+
+// runBulkAction runs the action concurrently for the contexts.
+func runBulkAction(cmd *cobra.Command, cfg *types.Config, contexts []string, action gcloud.Action) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	b, err := gcloud.NewBulk(ctx, cfg, contexts)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	m := newBulkModel(action, contexts, cancel)
+	p := tea.NewProgram(m)
+
+	var errs map[string]error
+	go func() {
+		errs = b.Run(ctx, contexts, action, func(context, msg string, waiting bool) {
+			p.Send(bulkProgressMsg{context: context, msg: msg, waiting: waiting})
+		})
+		p.Send(bulkDoneMsg{})
+	}()
+
+	if _, err := p.Run(); err != nil {
+		return err
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	cmd.Printf("\n%d of %d workstations failed to %s:\n", len(errs), len(contexts), action)
+	var all []error
+	for _, name := range contexts {
+		if err, ok := errs[name]; ok {
+			cmd.Printf("  %s: %v\n", name, err)
+			all = append(all, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(all...)
+}
+
+type (
+	bulkProgressMsg struct {
+		context string
+		msg     string
+		waiting bool
+	}
+	bulkDoneMsg struct{}
+)
+
+type bulkState struct {
+	msg     string
+	waiting bool
+}
+
+type bulkModel struct {
+	action   gcloud.Action
+	contexts []string
+	states   map[string]*bulkState
+	spinner  spinner.Model
+	cancel   context.CancelFunc
+	width    int
+}
+
+func newBulkModel(action gcloud.Action, contexts []string, cancel context.CancelFunc) *bulkModel {
+	m := &bulkModel{
+		action:   action,
+		contexts: contexts,
+		states:   make(map[string]*bulkState),
+		spinner:  spinner.New(spinner.WithSpinner(spinner.Dot)),
+		cancel:   cancel,
+	}
+	for _, name := range contexts {
+		m.states[name] = &bulkState{msg: "Pending ..."}
+		m.width = max(m.width, len(name))
+	}
+	return m
+}
+
+func (m *bulkModel) Init() tea.Cmd {
+	return m.spinner.Tick
+}
+
+func (m *bulkModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			m.cancel()
+		}
+	case bulkProgressMsg:
+		if st, ok := m.states[msg.context]; ok {
+			st.msg = msg.msg
+			st.waiting = msg.waiting
+		}
+	case bulkDoneMsg:
+		for _, st := range m.states {
+			st.waiting = false
+		}
+		return m, tea.Quit
+	case spinner.TickMsg:
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m *bulkModel) View() string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Running %s for %d workstations\n\n", m.action, len(m.contexts)))
+	for _, name := range m.contexts {
+		st := m.states[name]
+		icon := " "
+		if st.waiting {
+			icon = m.spinner.View()
+		}
+		b.WriteString(fmt.Sprintf("%s %-*s  %s\n", icon, m.width, name, st.msg))
+	}
+	return b.String()
+}
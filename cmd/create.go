@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/gws/internal/gcloud"
+	"github.com/bakito/gws/internal/log"
+)
+
+// createCmd represents the create command.
+var createCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a workstation",
+	Long: `Create the workstation defined by the gcloud config of the given or current context.
+Display name, labels, annotations and env variables can be defined in the workstation section of the context.`,
+	RunE: func(_ *cobra.Command, args []string) error {
+		if flagContext == "" && len(args) == 1 {
+			flagContext = args[0]
+		}
+
+		cfg, err := readConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := gcloud.CreateWorkstation(ctx, cfg); err != nil {
+			return err
+		}
+
+		sshContext := cfg.CurrentContext()
+		if sshContext.Host == "" {
+			sshContext.Host = "localhost"
+		}
+		log.Logf("💾 Writing context %q to %s", cfg.CurrentContextName, cfg.FilePath)
+		return cfg.SwitchContext(cfg.CurrentContextName, true)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(createCmd)
+}
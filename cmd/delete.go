@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/gws/internal/gcloud"
+	"github.com/bakito/gws/internal/log"
+)
+
+var (
+	flagYes           bool
+	flagConfirm       string
+	flagRemoveContext bool
+)
+
+// deleteCmd represents the delete command.
+var deleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a workstation",
+	Long: `Delete the workstation of the given or current context.
+The deletion must be confirmed by entering the workstation name, unless --yes or --confirm is used.
+A --confirm name not matching the workstation fails the command.`,
+	RunE: func(_ *cobra.Command, args []string) error {
+		if flagContext == "" && len(args) == 1 {
+			flagContext = args[0]
+		}
+
+		cfg, err := readConfig()
+		if err != nil {
+			return err
+		}
+
+		sshContext := cfg.CurrentContext()
+		if sshContext.GCloud == nil {
+			return gcloud.ErrNoGCloudConfig
+		}
+
+		confirm := flagConfirm
+		if flagYes {
+			confirm = sshContext.GCloud.Name
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := gcloud.DeleteWorkstation(ctx, cfg, confirm); err != nil {
+			if errors.Is(err, gcloud.ErrAborted) {
+				log.Log("Aborting ...")
+				return nil
+			}
+			return err
+		}
+
+		if err := gcloud.RemoveKnownHosts(sshContext); err != nil {
+			return err
+		}
+
+		if flagRemoveContext {
+			log.Logf("Removing context %q", cfg.CurrentContextName)
+			return cfg.RemoveContext(cfg.CurrentContextName)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(deleteCmd)
+	deleteCmd.PersistentFlags().
+		BoolVarP(&flagYes, "yes", "y", false, "Skip the confirmation prompt")
+	deleteCmd.PersistentFlags().
+		StringVar(&flagConfirm, "confirm", "", "Confirm the deletion by providing the workstation name")
+	deleteCmd.PersistentFlags().
+		BoolVar(&flagRemoveContext, "remove-context", false, "Remove the context from the config after deletion")
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/gws/internal/gcloud"
+	"github.com/bakito/gws/internal/types"
+)
+
+// forwardCmd represents the forward command.
+var forwardCmd = &cobra.Command{
+	Use:   "forward [port|local:remote]...",
+	Short: "Forward local ports to ports of a workstation",
+	Long: `Forward local ports to ports of the workstation of the current context.
+A port is either forwarded to the same port of the workstation or given as <local port>:<remote port>.
+If no ports are given, the forwards of the context are used.`,
+	Example: "  gws forward 5432 8080:3000 9229",
+	RunE: func(_ *cobra.Command, args []string) error {
+		forwards := make([]types.Forward, 0, len(args))
+		for _, arg := range args {
+			f, err := types.ParseForward(arg)
+			if err != nil {
+				return err
+			}
+			forwards = append(forwards, f)
+		}
+
+		cfg, err := readConfig()
+		if err != nil {
+			return err
+		}
+
+		if err := startWorkstation(cfg); err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return gcloud.ForwardPorts(ctx, cfg, forwards, flagRestart)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(forwardCmd)
+	addTimeoutFlag(forwardCmd)
+	addRestartFlag(forwardCmd)
+}
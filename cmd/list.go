@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/gws/internal/gcloud"
+)
+
+var flagAllContexts bool
+
+// listCmd represents the list command.
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List workstations and their state",
+	Long: `List all workstations in the project and region of the current context
+with their state, host, config and last start time.`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		cfg, err := readConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		infos, err := gcloud.ListWorkstations(ctx, cfg, flagAllContexts)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 3, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CONTEXT\tNAME\tSTATE\tHOST\tCONFIG\tLAST START")
+		for _, info := range infos {
+			started := "-"
+			if !info.StartTime.IsZero() {
+				started = info.StartTime.Local().Format(time.RFC822)
+			}
+			ctxName := info.Context
+			if ctxName == "" {
+				ctxName = "-"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				ctxName, info.Name, info.StateName(), info.Host, info.Config, started)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.PersistentFlags().
+		BoolVar(&flagAllContexts, "all-contexts", false, "List the workstations of the projects and regions of all contexts")
+}
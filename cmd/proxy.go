@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/gws/internal/gcloud"
+	"github.com/bakito/gws/internal/log"
+	"github.com/bakito/gws/internal/types"
+)
+
+// proxyCmd represents the proxy command.
+var proxyCmd = &cobra.Command{
+	Use:   "proxy [context]",
+	Short: "Bridge stdin and stdout to the ssh of a workstation, for use as OpenSSH ProxyCommand",
+	Long: `Bridge stdin and stdout to the ssh of the workstation, without a local port.
+The workstation is started if it is not running, all logs are written to stderr.
+
+Example ~/.ssh/config:
+
+  Host my-workstation
+    User user
+    ProxyCommand gws proxy %n`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		// stdout is reserved for the ssh connection
+		log.SetLogger(func(s string) {
+			_, _ = fmt.Fprintln(os.Stderr, s)
+		})
+
+		// the current context is not switched, as parallel ssh sessions share the config
+		cfg, err := loadConfig()
+		name := cfg.CurrentContextName
+		if flagContext != "" {
+			name = flagContext
+		}
+		if len(args) == 1 {
+			name = args[0]
+		}
+		// a missing current context does not matter, if the context is given
+		if err != nil && (name == cfg.CurrentContextName || !errors.Is(err, types.ErrContextNotDefined)) {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return gcloud.Proxy(ctx, cfg, name, os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(proxyCmd)
+	addTimeoutFlag(proxyCmd)
+}
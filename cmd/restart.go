@@ -14,7 +14,10 @@ import (
 var restartCmd = &cobra.Command{
 	Use:   "restart",
 	Short: "Restart a workstation",
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if isBulk() {
+			return runBulk(cmd, gcloud.ActionRestart)
+		}
 		if flagContext == "" && len(args) == 1 {
 			flagContext = args[0]
 		}
@@ -26,13 +29,12 @@ var restartCmd = &cobra.Command{
 
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 		defer stop()
-		if err := gcloud.StopWorkstation(ctx, cfg); err != nil {
-			return err
-		}
-		return gcloud.StartWorkstation(ctx, cfg)
+		return gcloud.RestartWorkstation(ctx, cfg)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(restartCmd)
+	addBulkFlags(restartCmd)
+	addTimeoutFlag(restartCmd)
 }
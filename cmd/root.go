@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"errors"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -16,20 +18,53 @@ var (
 		Short:   "Google Cloud Workstation Utils",
 		Version: version.Version,
 	}
-	flagConfig  string
-	flagContext string
+	flagConfig    string
+	flagContext   string
+	flagTimeout   time.Duration
+	flagNoBrowser bool
+	flagRestart   bool
 )
 
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
+		var ece *exitCodeError
+		if errors.As(err, &ece) {
+			os.Exit(ece.code)
+		}
 		os.Exit(1)
 	}
 }
 
+// exitCodeError is an error that terminates gws with a specific exit code.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
 func init() {
 	rootCmd.PersistentFlags().StringVar(&flagContext, "ctx", "", "The context to be used")
 	rootCmd.PersistentFlags().StringVarP(&flagConfig, "config", "c", types.ConfigFileName, "The config file to be used")
+	rootCmd.PersistentFlags().BoolVar(&flagNoBrowser, "no-browser", false,
+		"Login without opening a browser by pasting the redirect URL or code")
+}
+
+func addTimeoutFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().DurationVar(&flagTimeout, "timeout", 0,
+		"The timeout for the workstation to reach the desired state (default is the context timeout or 10m)")
+}
+
+func addRestartFlag(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&flagRestart, "restart", false,
+		"Restart the workstation if it stopped while the connection is open")
 }
 
 func readConfig() (*types.Config, error) {
@@ -48,5 +83,7 @@ func readConfig() (*types.Config, error) {
 func loadConfig() (*types.Config, error) {
 	config := &types.Config{Contexts: make(map[string]*types.Context)}
 	err := config.Load(flagConfig)
+	config.Timeout = flagTimeout
+	config.NoBrowser = flagNoBrowser
 	return config, err
 }
@@ -11,11 +11,16 @@ import (
 	"github.com/bakito/gws/internal/types"
 )
 
+var flagWaitSSH bool
+
 // startCmd represents the start command.
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start a workstation",
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if isBulk() {
+			return runBulk(cmd, gcloud.ActionStart)
+		}
 		if flagContext == "" && len(args) == 1 {
 			flagContext = args[0]
 		}
@@ -25,7 +30,13 @@ var startCmd = &cobra.Command{
 			return err
 		}
 
-		return startWorkstation(cfg)
+		if err := startWorkstation(cfg); err != nil {
+			return err
+		}
+		if flagWaitSSH {
+			return waitForSSH(cfg)
+		}
+		return nil
 	},
 }
 
@@ -35,6 +46,18 @@ func startWorkstation(cfg *types.Config) error {
 	return gcloud.StartWorkstation(ctx, cfg)
 }
 
+func waitForSSH(cfg *types.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return gcloud.WaitForSSH(ctx, cfg)
+}
+
 func init() {
 	rootCmd.AddCommand(startCmd)
+	addBulkFlags(startCmd)
+	addTimeoutFlag(startCmd)
+	startCmd.PersistentFlags().
+		BoolVar(&flagWaitSSH, "wait-ssh", false, "Wait until the workstation ssh answers and the readiness commands succeed")
+	startCmd.MarkFlagsMutuallyExclusive("wait-ssh", "all")
+	startCmd.MarkFlagsMutuallyExclusive("wait-ssh", "group")
 }
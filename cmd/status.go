@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"cloud.google.com/go/workstations/apiv1/workstationspb"
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/gws/internal/gcloud"
+)
+
+// statusExitCodes the exit codes of the status command per workstation state.
+var statusExitCodes = map[workstationspb.Workstation_State]int{
+	workstationspb.Workstation_STATE_RUNNING:     0,
+	workstationspb.Workstation_STATE_STOPPED:     3,
+	workstationspb.Workstation_STATE_STARTING:    4,
+	workstationspb.Workstation_STATE_STOPPING:    5,
+	workstationspb.Workstation_STATE_UNSPECIFIED: 6,
+}
+
+// statusCmd represents the status command.
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of a workstation",
+	Long: `Show the state, host, uptime, timeouts and local tunnel of the workstation for the given or current context.
+
+The exit code reflects the state of the workstation:
+  0: running
+  1: error
+  3: stopped
+  4: starting
+  5: stopping
+  6: unknown`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if flagContext == "" && len(args) == 1 {
+			flagContext = args[0]
+		}
+
+		cfg, err := readConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		status, err := gcloud.GetStatus(ctx, cfg)
+		if err != nil {
+			return err
+		}
+
+		cmd.Printf("Context:         %s\n", status.Context)
+		cmd.Printf("Workstation:     %s\n", status.Name)
+		cmd.Printf("Config:          %s\n", status.Config)
+		cmd.Printf("State:           %s\n", status.StateName())
+		cmd.Printf("Reconciling:     %t\n", status.Reconciling)
+		cmd.Printf("Host:            %s\n", status.Host)
+		if !status.StartTime.IsZero() {
+			cmd.Printf("Last Start:      %s\n", status.StartTime.Local().Format(time.RFC822))
+		}
+		if uptime := status.Uptime(); uptime > 0 {
+			cmd.Printf("Uptime:          %s\n", uptime.Truncate(time.Second))
+		}
+		cmd.Printf("Idle Timeout:    %s\n", status.IdleTimeout)
+		cmd.Printf("Running Timeout: %s\n", status.RunningTimeout)
+		tunnel := "not listening"
+		if status.TunnelListening {
+			tunnel = "listening"
+		}
+		cmd.Printf("Local Tunnel:    %s (%s)\n", status.TunnelAddress, tunnel)
+
+		code, ok := statusExitCodes[status.State]
+		if !ok {
+			code = statusExitCodes[workstationspb.Workstation_STATE_UNSPECIFIED]
+		}
+		if code != 0 {
+			cmd.SilenceErrors = true
+			return &exitCodeError{code: code, err: fmt.Errorf("workstation is %s", status.StateName())}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -14,7 +14,10 @@ import (
 var stopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "Stop a workstation",
-	RunE: func(_ *cobra.Command, args []string) error {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if isBulk() {
+			return runBulk(cmd, gcloud.ActionStop)
+		}
 		if flagContext == "" && len(args) == 1 {
 			flagContext = args[0]
 		}
@@ -32,4 +35,6 @@ var stopCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(stopCmd)
+	addBulkFlags(stopCmd)
+	addTimeoutFlag(stopCmd)
 }
@@ -1,22 +1,40 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
 
+	"github.com/bakito/gws/internal/gcloud"
+	"github.com/bakito/gws/internal/log"
 	"github.com/bakito/gws/internal/tunnel"
+	"github.com/bakito/gws/internal/types"
 )
 
 var (
 	flagLocalPort  int
 	flagTokenCheck bool
+	flagDetach     bool
+	flagDaemon     bool
 )
 
 // tunnelCmd represents the tunnel command.
 var tunnelCmd = &cobra.Command{
-	Use:   "tunnel",
+	Use:   "tunnel [context]...",
 	Short: "tunnel a workstation",
+	Long: `Tunnel the workstation of the current or given context.
+With several contexts, --all or --group one tunnel per context is opened in the same process.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if flagDaemon {
+			return runDaemon(args)
+		}
+		if len(args) > 1 || isBulk() {
+			return runTunnels(cmd, args)
+		}
+
 		if flagContext == "" && len(args) == 1 {
 			flagContext = args[0]
 		}
@@ -30,17 +48,89 @@ var tunnelCmd = &cobra.Command{
 			return err
 		}
 
-		m := tunnel.NewModel(cmd.Context(), cfg, flagLocalPort)
-		p := tea.NewProgram(m, tea.WithAltScreen())
-		_, err = p.Run()
-		return err
+		if flagDetach {
+			return detachTunnel(cfg, cfg.CurrentContextName)
+		}
+
+		return runTunnelModel(cmd, cfg, []string{cfg.CurrentContextName})
 	},
 }
 
+// runTunnels opens the tunnels of several contexts.
+func runTunnels(cmd *cobra.Command, args []string) error {
+	if isBulk() && len(args) > 0 {
+		return errors.New("contexts can not be combined with --all or --group")
+	}
+	if flagLocalPort != 0 {
+		return errors.New("--local-host-port can only be used with a single context")
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	contexts := args
+	if isBulk() {
+		if contexts, err = bulkContexts(cfg); err != nil {
+			return err
+		}
+		// one context of a group without workstation does not prevent the tunnels of the others
+		contexts = slices.DeleteFunc(contexts, func(name string) bool {
+			if cfg.Contexts[name].GCloud == nil {
+				log.Logf("⚠️ Skipping context %q without gcloud config", name)
+				return true
+			}
+			return false
+		})
+		if len(contexts) == 0 {
+			return gcloud.ErrNoGCloudConfig
+		}
+	}
+	for _, name := range contexts {
+		sshContext, err := cfg.Context(name)
+		if err != nil {
+			return err
+		}
+		if sshContext.GCloud == nil {
+			return fmt.Errorf("context %q: %w", name, gcloud.ErrNoGCloudConfig)
+		}
+	}
+
+	// the failed workstations are reported, their tabs show the error while the others are tunneled
+	if err := runBulkAction(cmd, cfg, contexts, gcloud.ActionStart); err != nil && !isBulk() {
+		return err
+	}
+
+	if flagDetach {
+		var errs []error
+		for _, name := range contexts {
+			if err := detachTunnel(cfg, name); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+	return runTunnelModel(cmd, cfg, contexts)
+}
+
+func runTunnelModel(cmd *cobra.Command, cfg *types.Config, contexts []string) error {
+	m := tunnel.NewModel(cmd.Context(), cfg, contexts, flagLocalPort, flagRestart)
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	_, err := p.Run()
+	return err
+}
+
 func init() {
 	rootCmd.AddCommand(tunnelCmd)
+	addTimeoutFlag(tunnelCmd)
 	tunnelCmd.PersistentFlags().
 		IntVarP(&flagLocalPort, "local-host-port", "p", 0, "The local host port to open (default ist the port from the config)")
 	tunnelCmd.PersistentFlags().
 		BoolVar(&flagTokenCheck, "check-token", true, "Enable periodic token check")
+	addRestartFlag(tunnelCmd)
+	addBulkFlags(tunnelCmd)
+	tunnelCmd.Flags().BoolVarP(&flagDetach, "detach", "d", false,
+		"Run the tunnel in the background, manage it with 'gws tunnel ls|logs|stop'")
+	tunnelCmd.Flags().BoolVar(&flagDaemon, "daemon", false, "Run as detached tunnel daemon")
+	_ = tunnelCmd.Flags().MarkHidden("daemon")
 }
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bakito/gws/internal/daemon"
+	"github.com/bakito/gws/internal/gcloud"
+	"github.com/bakito/gws/internal/log"
+	"github.com/bakito/gws/internal/tunnel"
+	"github.com/bakito/gws/internal/types"
+)
+
+// tunnelLsCmd represents the tunnel ls command.
+var tunnelLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the detached tunnels",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		tunnels, err := daemon.List()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 3, ' ', 0)
+		_, _ = fmt.Fprintln(w, "CONTEXT\tPID\tLISTEN\tSTATE\tCONNECTIONS\tIN\tOUT\tSTARTED")
+		for _, t := range tunnels {
+			status := t.Status
+			if status == nil {
+				status = &daemon.Status{State: "-"}
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d/%d\t%s\t%s\t%s\n",
+				t.Context, t.PID, t.Address, status.State, status.Connections, status.Total,
+				tunnel.FormatBytes(float64(status.BytesIn)), tunnel.FormatBytes(float64(status.BytesOut)),
+				t.Started.Local().Format(time.RFC822))
+		}
+		return w.Flush()
+	},
+}
+
+// tunnelLogsCmd represents the tunnel logs command.
+var tunnelLogsCmd = &cobra.Command{
+	Use:   "logs <context>",
+	Short: "Show the logs of a detached tunnel",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lines, err := daemon.Logs(args[0])
+		if errors.Is(err, daemon.ErrNotRunning) {
+			// show the log file of a stopped or died tunnel
+			logFile, lfErr := daemon.LogFile(args[0])
+			if lfErr != nil {
+				return lfErr
+			}
+			data, rErr := os.ReadFile(logFile)
+			if rErr != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		for _, l := range lines {
+			_, _ = fmt.Fprintln(cmd.OutOrStdout(), l)
+		}
+		return nil
+	},
+}
+
+// tunnelStopCmd represents the tunnel stop command.
+var tunnelStopCmd = &cobra.Command{
+	Use:   "stop <context>",
+	Short: "Stop a detached tunnel",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		t, err := daemon.Stop(args[0])
+		if err != nil {
+			return err
+		}
+		log.Logf("🛑 Stopped the detached tunnel of context %s (pid %d)", t.Context, t.PID)
+		return nil
+	},
+}
+
+func init() {
+	tunnelCmd.AddCommand(tunnelLsCmd, tunnelLogsCmd, tunnelStopCmd)
+}
+
+// detachTunnel starts the tunnel of the context as daemon in the background.
+func detachTunnel(cfg *types.Config, name string) error {
+	configFile, err := filepath.Abs(cfg.FilePath)
+	if err != nil {
+		return err
+	}
+	args := []string{"tunnel", "--daemon", name, "--config", configFile}
+	if flagLocalPort != 0 {
+		args = append(args, "--local-host-port", strconv.Itoa(flagLocalPort))
+	}
+	if flagRestart {
+		args = append(args, "--restart")
+	}
+	if flagTimeout != 0 {
+		args = append(args, "--timeout", flagTimeout.String())
+	}
+
+	t, err := daemon.Start(name, args)
+	if err != nil {
+		return err
+	}
+	log.Logf("🚀 Detached tunnel of context %s listening on %s (pid %d)", t.Context, t.Address, t.PID)
+	log.Logf("📜 Logs: gws tunnel logs %s", t.Context)
+	log.Logf("🛑 Stop: gws tunnel stop %s", t.Context)
+	return nil
+}
+
+// runDaemon runs the tunnel of the context as detached daemon.
+// The current context is not switched, as several daemons share the config.
+func runDaemon(args []string) error {
+	if len(args) != 1 {
+		return errors.New("the daemon requires exactly one context")
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return daemon.Run(ctx, cfg, gcloud.TunnelOptions{Context: args[0], Port: flagLocalPort, Restart: flagRestart})
+}
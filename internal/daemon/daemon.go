@@ -0,0 +1,159 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bakito/gws/internal/types"
+)
+
+const (
+	// DirName the name of the directory in the gws config dir holding the state of the detached tunnels.
+	DirName = "tunnels"
+
+	stateSuffix  = ".json"
+	socketSuffix = ".sock"
+	logSuffix    = ".log"
+)
+
+// ErrNotRunning is returned when no detached tunnel is running for a context.
+var ErrNotRunning = errors.New("no detached tunnel running")
+
+// State the state file of a detached tunnel.
+type State struct {
+	PID     int       `json:"pid"`
+	Context string    `json:"context"`
+	Address string    `json:"address"`
+	Socket  string    `json:"socket"`
+	LogFile string    `json:"logFile"`
+	Started time.Time `json:"started"`
+}
+
+// Dir returns the per-user directory of the detached tunnels and creates it if missing.
+func Dir() (string, error) {
+	userHomeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(userHomeDir, types.ConfigDir, DirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// paths returns the state file, control socket and log file of the context.
+func paths(context string) (stateFile, socket, logFile string, err error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", "", "", err
+	}
+	base := filepath.Join(dir, context)
+	return base + stateSuffix, base + socketSuffix, base + logSuffix, nil
+}
+
+// LogFile returns the log file of the detached tunnel of the context.
+func LogFile(context string) (string, error) {
+	_, _, logFile, err := paths(context)
+	return logFile, err
+}
+
+func readState(stateFile string) (*State, error) {
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, err
+	}
+	s := &State{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", stateFile, err)
+	}
+	return s, nil
+}
+
+func writeState(stateFile string, s *State) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFile, data, 0o600)
+}
+
+// remove deletes the state file and the control socket of the tunnel.
+func (s *State) remove() {
+	stateFile, _, _, err := paths(s.Context)
+	if err == nil {
+		_ = os.Remove(stateFile)
+	}
+	_ = os.Remove(s.Socket)
+}
+
+// Tunnel a detached tunnel with its live status.
+type Tunnel struct {
+	State
+	Status *Status
+}
+
+// List returns the running detached tunnels sorted by context. Stale state files of
+// tunnels that are not running anymore are cleaned up.
+func List() ([]Tunnel, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var tunnels []Tunnel
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), stateSuffix) {
+			continue
+		}
+		t, err := Get(strings.TrimSuffix(e.Name(), stateSuffix))
+		if err != nil {
+			if errors.Is(err, ErrNotRunning) {
+				continue
+			}
+			return nil, err
+		}
+		tunnels = append(tunnels, *t)
+	}
+	slices.SortFunc(tunnels, func(a, b Tunnel) int { return strings.Compare(a.Context, b.Context) })
+	return tunnels, nil
+}
+
+// Get returns the running detached tunnel of the context, its status is nil if the daemon does not answer.
+// A stale state file of a daemon that is not running anymore is cleaned up and ErrNotRunning is returned.
+func Get(context string) (*Tunnel, error) {
+	stateFile, _, _, err := paths(context)
+	if err != nil {
+		return nil, err
+	}
+	s, err := readState(stateFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w for context %q", ErrNotRunning, context)
+		}
+		return nil, err
+	}
+
+	status, err := request(s.Socket, commandStatus)
+	if err != nil {
+		if !alive(s.PID) || errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			// the daemon died without cleaning up
+			s.remove()
+			return nil, fmt.Errorf("%w for context %q", ErrNotRunning, context)
+		}
+		// the daemon is running, but busy
+		return &Tunnel{State: *s}, nil
+	}
+	return &Tunnel{State: *s, Status: status.Status}, nil
+}
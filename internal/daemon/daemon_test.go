@@ -0,0 +1,94 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Daemon", func() {
+	BeforeEach(func() {
+		homeDir := GinkgoT().TempDir()
+		GinkgoT().Setenv("HOME", homeDir)
+		GinkgoT().Setenv("USERPROFILE", homeDir)
+	})
+
+	It("should answer on the control socket", func() {
+		stateFile, socket, logFile, err := paths("test")
+		Ω(err).ShouldNot(HaveOccurred())
+		listener, err := net.Listen("unix", socket)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer listener.Close()
+
+		go serve(listener, func(command string) controlResponse {
+			switch command {
+			case commandStatus:
+				return controlResponse{Status: &Status{State: "listening", Connections: 1}}
+			case commandLogs:
+				return controlResponse{Logs: []string{"line"}}
+			default:
+				return controlResponse{Error: "unknown"}
+			}
+		})
+		Ω(writeState(stateFile, &State{
+			PID: os.Getpid(), Context: "test", Socket: socket, LogFile: logFile, Started: time.Now(),
+		})).ShouldNot(HaveOccurred())
+
+		tunnels, err := List()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(tunnels).Should(HaveLen(1))
+		Ω(tunnels[0].Context).Should(Equal("test"))
+		Ω(tunnels[0].Status).Should(Equal(&Status{State: "listening", Connections: 1}))
+
+		Ω(Logs("test")).Should(Equal([]string{"line"}))
+
+		_, err = request(socket, "unknown")
+		Ω(err).Should(MatchError("unknown"))
+	})
+
+	It("should clean up a stale tunnel", func() {
+		stateFile, socket, _, err := paths("stale")
+		Ω(err).ShouldNot(HaveOccurred())
+		// the socket of a killed daemon might still accept connections, e.g. if inherited by a child process
+		listener, err := net.Listen("unix", socket)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer listener.Close()
+		Ω(writeState(stateFile, &State{PID: deadPID(), Context: "stale", Socket: socket})).ShouldNot(HaveOccurred())
+
+		_, err = Get("stale")
+		Ω(err).Should(MatchError(ErrNotRunning))
+		Ω(stateFile).ShouldNot(BeAnExistingFile())
+
+		tunnels, err := List()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(tunnels).Should(BeEmpty())
+		_, err = os.Stat(socket)
+		Ω(os.IsNotExist(err)).Should(BeTrue())
+	})
+
+	It("should keep a running tunnel not answering in time", func() {
+		stateFile, socket, _, err := paths("busy")
+		Ω(err).ShouldNot(HaveOccurred())
+		// the connection is accepted by the backlog, but never answered
+		listener, err := net.Listen("unix", socket)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer listener.Close()
+		Ω(writeState(stateFile, &State{PID: os.Getpid(), Context: "busy", Socket: socket})).ShouldNot(HaveOccurred())
+
+		t, err := Get("busy")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(t.Status).Should(BeNil())
+		Ω(stateFile).Should(BeAnExistingFile())
+	})
+})
+
+// deadPID returns the pid of an exited process.
+func deadPID() int {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	Ω(cmd.Run()).Should(Succeed())
+	return cmd.Process.Pid
+}
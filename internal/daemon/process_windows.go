@@ -0,0 +1,28 @@
+//go:build windows
+
+package daemon
+
+import "os"
+
+// alive returns true if the process with the pid is running.
+func alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// the process handle can only be opened for an existing process
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
+
+// terminate stops the process with the pid, windows has no signal for a graceful shutdown.
+func terminate(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+const (
+	commandStatus = "status"
+	commandLogs   = "logs"
+	commandStop   = "stop"
+
+	requestTimeout = 2 * time.Second
+)
+
+// Status the live status of a detached tunnel.
+type Status struct {
+	State       string `json:"state"`
+	Connections int    `json:"connections"`
+	Total       int64  `json:"total"`
+	BytesIn     int64  `json:"bytesIn"`
+	BytesOut    int64  `json:"bytesOut"`
+}
+
+type controlRequest struct {
+	Command string `json:"command"`
+}
+
+type controlResponse struct {
+	Error  string   `json:"error,omitempty"`
+	Status *Status  `json:"status,omitempty"`
+	Logs   []string `json:"logs,omitempty"`
+}
+
+// request sends the command to the control socket and returns the response.
+func request(socket, command string) (*controlResponse, error) {
+	conn, err := net.DialTimeout("unix", socket, requestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	if err := json.NewEncoder(conn).Encode(controlRequest{Command: command}); err != nil {
+		return nil, err
+	}
+	resp := &controlResponse{}
+	if err := json.NewDecoder(conn).Decode(resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// Logs returns the recent log lines of the detached tunnel of the context.
+func Logs(context string) ([]string, error) {
+	t, err := Get(context)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := request(t.Socket, commandLogs)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Logs, nil
+}
+
+// Stop stops the detached tunnel of the context and waits until it exited.
+// A daemon not answering on its control socket is terminated by its pid.
+func Stop(context string) (*Tunnel, error) {
+	t, err := Get(context)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := request(t.Socket, commandStop); err != nil {
+		if err := terminate(t.PID); err != nil {
+			return nil, fmt.Errorf("failed to stop the detached tunnel with pid %d: %w", t.PID, err)
+		}
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if !alive(t.PID) {
+			// a terminated daemon might not have cleaned up
+			t.remove()
+			return t, nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil, errors.New("the detached tunnel did not stop in time")
+}
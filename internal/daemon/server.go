@@ -0,0 +1,160 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bakito/gws/internal/gcloud"
+	"github.com/bakito/gws/internal/log"
+	"github.com/bakito/gws/internal/types"
+)
+
+// maxLogLines the number of recent log lines kept by the daemon.
+const maxLogLines = 200
+
+// logBuffer keeps the recent log lines.
+type logBuffer struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (b *logBuffer) add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, line)
+	if len(b.lines) > maxLogLines {
+		b.lines = b.lines[len(b.lines)-maxLogLines:]
+	}
+}
+
+func (b *logBuffer) get() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]string(nil), b.lines...)
+}
+
+// Run runs the tunnel of the context of the options as daemon until ctx is done or it is stopped over the control socket.
+// The logs are written to stdout, which is redirected to the log file by Start.
+func Run(ctx context.Context, cfg *types.Config, opts gcloud.TunnelOptions) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	name := opts.Context
+	if name == "" {
+		name = cfg.CurrentContextName
+	}
+	sshContext, err := cfg.Context(name)
+	if err != nil {
+		return err
+	}
+	opts.Context = name
+	stateFile, socket, logFile, err := paths(name)
+	if err != nil {
+		return err
+	}
+
+	logs := &logBuffer{}
+	log.SetLogger(func(s string) {
+		line := time.Now().Format(time.DateTime) + " " + s
+		logs.add(line)
+		_, _ = fmt.Fprintln(os.Stdout, line)
+	})
+
+	// a socket left over by a died daemon
+	_ = os.Remove(socket)
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "unix", socket)
+	if err != nil {
+		return fmt.Errorf("failed to open the control socket: %w", err)
+	}
+	defer func() { _ = listener.Close() }()
+	if err := os.Chmod(socket, 0o600); err != nil {
+		return err
+	}
+
+	network, address := sshContext.ListenAddress(opts.Port)
+	if network == "unix" {
+		address = types.ListenUnixPrefix + address
+	}
+	state := &State{
+		PID:     os.Getpid(),
+		Context: name,
+		Address: address,
+		Socket:  socket,
+		LogFile: logFile,
+		Started: time.Now(),
+	}
+	if err := writeState(stateFile, state); err != nil {
+		return err
+	}
+	defer state.remove()
+
+	var (
+		mu          sync.Mutex
+		tunnelState = gcloud.TunnelState("starting")
+	)
+	opts.Metrics = gcloud.NewTunnelMetrics()
+	opts.OnState = func(s gcloud.TunnelState) {
+		mu.Lock()
+		tunnelState = s
+		mu.Unlock()
+	}
+
+	go serve(listener, func(command string) controlResponse {
+		switch command {
+		case commandStatus:
+			stats := opts.Metrics.Snapshot()
+			mu.Lock()
+			defer mu.Unlock()
+			return controlResponse{Status: &Status{
+				State:       string(tunnelState),
+				Connections: len(stats.Connections),
+				Total:       stats.Total,
+				BytesIn:     stats.BytesIn,
+				BytesOut:    stats.BytesOut,
+			}}
+		case commandLogs:
+			return controlResponse{Logs: logs.get()}
+		case commandStop:
+			log.Log("🛑 Stopping the detached tunnel")
+			cancel()
+			return controlResponse{}
+		default:
+			return controlResponse{Error: fmt.Sprintf("unknown command %q", command)}
+		}
+	})
+
+	err = gcloud.TCPTunnelWithPassphrase(ctx, cfg, opts)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	if err != nil {
+		log.Logf("🚨 Tunnel failed: %v", err)
+	}
+	return err
+}
+
+// serve answers the requests on the control socket until the listener is closed.
+func serve(listener net.Listener, handle func(command string) controlResponse) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer func() { _ = conn.Close() }()
+			_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+
+			var req controlRequest
+			if err := json.NewDecoder(conn).Decode(&req); err != nil {
+				return
+			}
+			_ = json.NewEncoder(conn).Encode(handle(req.Command))
+		}()
+	}
+}
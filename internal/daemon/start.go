@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// startTimeout the maximum duration to wait for the daemon to answer on its control socket.
+const startTimeout = 30 * time.Second
+
+// Start starts the gws executable with the args as detached daemon for the context
+// and waits until it answers on its control socket.
+func Start(context string, args []string) (*Tunnel, error) {
+	if t, err := Get(context); err == nil {
+		return nil, fmt.Errorf("a detached tunnel for context %q is already running (pid %d)", context, t.PID)
+	} else if !errors.Is(err, ErrNotRunning) {
+		return nil, err
+	}
+
+	_, _, logFile, err := paths(context)
+	if err != nil {
+		return nil, err
+	}
+	out, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = out.Close() }()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.SysProcAttr = sysProcAttr()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the detached tunnel: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(startTimeout)
+	for {
+		select {
+		case err := <-exited:
+			return nil, fmt.Errorf("the detached tunnel exited (%v), see the logs in %s", err, logFile)
+		case <-deadline:
+			return nil, fmt.Errorf("the detached tunnel did not start in time, see the logs in %s", logFile)
+		case <-time.After(200 * time.Millisecond):
+			if t, err := Get(context); err == nil {
+				// the daemon keeps running after gws exits
+				_ = cmd.Process.Release()
+				return t, nil
+			}
+		}
+	}
+}
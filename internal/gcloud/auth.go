@@ -4,13 +4,15 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
-	golog "log"
 	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/phayes/freeport"
@@ -21,28 +23,60 @@ import (
 	"github.com/bakito/gws/internal/types"
 )
 
-var oauthConfig = &oauth2.Config{
-	ClientID:     clientID,
-	ClientSecret: clientSecret,
-	Scopes: []string{
-		"openid",
-		"https://www.googleapis.com/auth/userinfo.email",
-		"https://www.googleapis.com/auth/cloud-platform",
-		"https://www.googleapis.com/auth/appengine.admin",
-		"https://www.googleapis.com/auth/compute",
-	},
-	Endpoint: google.Endpoint,
+var defaultScopes = []string{
+	"openid",
+	"https://www.googleapis.com/auth/userinfo.email",
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/appengine.admin",
+	"https://www.googleapis.com/auth/compute",
+}
+
+// oauthConfig returns the OAuth config of the configured client.
+// Values not configured fall back to the gcloud client embedded at build time.
+func oauthConfig(cfg *types.Config) *oauth2.Config {
+	client := cfg.OAuthClient()
+	conf := &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       defaultScopes,
+		Endpoint:     google.Endpoint,
+	}
+	if client.ClientID != "" {
+		// the embedded secret belongs to the embedded client ID
+		conf.ClientID = client.ClientID
+		conf.ClientSecret = client.ClientSecret
+	}
+	if len(client.Scopes) > 0 {
+		conf.Scopes = client.Scopes
+	}
+	if client.AuthURL != "" {
+		conf.Endpoint.AuthURL = client.AuthURL
+	}
+	if client.TokenURL != "" {
+		conf.Endpoint.TokenURL = client.TokenURL
+	}
+	return conf
+}
+
+// loginTimeout the maximum duration to wait for the user to complete the login.
+var loginTimeout = 5 * time.Minute
+
+// randomString returns a base64 URL encoded random string of 32 bytes.
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
 // Generate PKCE Code Verifier and SHA-256 Code Challenge.
-func generatePKCE() (codeVerifier, codeChallenge string) {
+func generatePKCE() (codeVerifier, codeChallenge string, err error) {
 	// Create a random 43-128 character code verifier
-	verifierBytes := make([]byte, 32)
-	_, err := rand.Read(verifierBytes)
+	codeVerifier, err = randomString()
 	if err != nil {
-		golog.Fatalf("🚨 Failed to generate PKCE verifier: %v", err)
+		return "", "", fmt.Errorf("failed to generate PKCE verifier: %w", err)
 	}
-	codeVerifier = base64.RawURLEncoding.EncodeToString(verifierBytes)
 
 	// Create the SHA-256 hash of the verifier
 	hash := sha256.Sum256([]byte(codeVerifier))
@@ -50,157 +84,235 @@ func generatePKCE() (codeVerifier, codeChallenge string) {
 	// Base64 URL encode the hash to create the code challenge
 	codeChallenge = base64.RawURLEncoding.EncodeToString(hash[:])
 
-	return codeVerifier, codeChallenge
+	return codeVerifier, codeChallenge, nil
 }
 
-func Login(ctx context.Context, cfg *types.Config) (oauth2.TokenSource, error) {
-	existingToken := cfg.Token.Token
+// Login returns a token source for the account, if account is empty the default account is used.
+// The user is only prompted to login if the account has no valid token.
+func Login(ctx context.Context, cfg *types.Config, account string) (oauth2.TokenSource, error) {
+	if err := cfg.LoadToken(); err != nil {
+		return nil, err
+	}
+	account = cfg.ResolveAccount(account)
 
 	// Try refreshing the token
-	if existingToken.RefreshToken != "" {
-		tokenSource := oauthConfig.TokenSource(ctx, &existingToken)
-		token, err := tokenSource.Token()
-		if err == nil {
-			_ = cfg.SetToken(*token)
-			return newTokenSourceWithRefreshCheck(ctx, token, cfg), nil
+	if cfg.AccountToken(account).Token.RefreshToken != "" {
+		m := newTokenManager(ctx, cfg, account, nil)
+		if _, err := m.Token(); err == nil {
+			return m, nil
 		}
+		m.Stop()
 	}
 
-	codeVerifier, codeChallenge := generatePKCE()
+	return login(ctx, cfg, account)
+}
+
+// login runs the OAuth flow with PKCE in the browser or headless.
+func login(ctx context.Context, cfg *types.Config, account string) (oauth2.TokenSource, error) {
+	loginCtx, cancel := context.WithTimeout(ctx, loginTimeout)
+	defer cancel()
 
-	port, err := freeport.GetFreePort()
+	codeVerifier, codeChallenge, err := generatePKCE()
 	if err != nil {
 		return nil, err
 	}
-
-	//nolint: revive // http is ok for local callback
-	oauthConfig.RedirectURL = fmt.Sprintf("http://%s/callback", net.JoinHostPort("localhost", strconv.Itoa(port)))
+	state, err := randomString()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate OAuth state: %w", err)
+	}
 
 	// Add PKCE to auth URL
-	authURL := oauthConfig.AuthCodeURL("state", oauth2.AccessTypeOffline,
+	opts := []oauth2.AuthCodeOption{
+		oauth2.AccessTypeOffline,
 		oauth2.SetAuthURLParam("code_challenge", codeChallenge),
 		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
-	)
+	}
+	if account != "" {
+		log.Logf("🔑 Please login with account %s", account)
+		opts = append(opts, oauth2.SetAuthURLParam("login_hint", account))
+	}
 
-	// Open URL in browser
-	log.Log("Opening URL: " + authURL)
-	openBrowser(authURL)
+	f := &oauthFlow{config: *oauthConfig(cfg), state: state, codeVerifier: codeVerifier, opts: opts}
+	var token *oauth2.Token
+	if cfg.NoBrowser {
+		token, err = f.headlessLogin(loginCtx)
+	} else {
+		token, err = f.browserLogin(loginCtx)
+	}
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, fmt.Errorf("login not completed within %s", loginTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Save token
+	account, err = cfg.SetToken(account, *token)
+	if err != nil {
+		return nil, err
+	}
+	log.Logf("Authenticated as %s...", account)
+	return newTokenManager(ctx, cfg, account, token), nil
+}
+
+// oauthFlow holds the state of a single authorization code flow.
+type oauthFlow struct {
+	config       oauth2.Config
+	state        string
+	codeVerifier string
+	opts         []oauth2.AuthCodeOption
+}
+
+// authCodeURL sets the redirect URL to the local callback on the port and returns the auth URL.
+func (f *oauthFlow) authCodeURL(port int) string {
+	//nolint: revive // http is ok for local callback
+	f.config.RedirectURL = fmt.Sprintf("http://%s/callback", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	return f.config.AuthCodeURL(f.state, f.opts...)
+}
+
+// browserLogin opens the auth URL in the browser and waits for the code on the local callback.
+func (f *oauthFlow) browserLogin(ctx context.Context) (*oauth2.Token, error) {
+	// Listen on loopback only, the callback must not be reachable from other hosts
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the OAuth callback server: %w", err)
+	}
+	addr, ok := listener.Addr().(*net.TCPAddr)
+	if !ok {
+		closeIt(listener)
+		return nil, fmt.Errorf("unexpected listener address %s", listener.Addr())
+	}
+	authURL := f.authCodeURL(addr.Port)
 
-	// Create a channel for shutdown signaling
-	shutdownChan := make(chan *oauth2.Token)
+	type result struct {
+		token *oauth2.Token
+		err   error
+	}
+	// buffered, only the first callback completes the login
+	results := make(chan result, 1)
 
-	server := &http.Server{Addr: fmt.Sprintf(":%d", port), ReadHeaderTimeout: 1 * time.Second}
-	// Start a local server to handle callback
-	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		query := r.URL.Query()
-		code := query.Get("code")
-		if code == "" {
-			http.Error(w, "Missing code", http.StatusBadRequest)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		code, err := f.parseCallback(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Exchange authorization code for token
-
-		token, err := oauthConfig.Exchange(ctx, code,
-			oauth2.SetAuthURLParam("code_verifier", codeVerifier),
-			oauth2.SetAuthURLParam("client_secret", oauthConfig.ClientSecret),
-		)
+		token, err := f.exchange(r.Context(), code)
 		if err != nil {
 			http.Error(w, "Failed to get token", http.StatusInternalServerError)
-			golog.Fatalf("🚨 OAuth Exchange error: %v", err)
+			err = fmt.Errorf("oauth exchange error: %w", err)
+		} else {
+			fmt.Fprint(w, "Authentication successful! You can close this window.")
+		}
+		select {
+		case results <- result{token: token, err: err}:
+		default:
 		}
-
-		// Save token
-		_ = cfg.SetToken(*token)
-
-		fmt.Fprint(w, "Authentication successful! You can close this window.")
-		// Signal shutdown using a channel
-		go func() {
-			shutdownChan <- token
-		}()
 	})
 
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 1 * time.Second}
 	go func() {
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			golog.Fatalf("🚨Failed to start server: %v", err)
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			select {
+			case results <- result{err: fmt.Errorf("OAuth callback server failed: %w", err)}:
+			default:
+			}
 		}
 	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
 
-	log.Log("Waiting for authentication...")
-	// Block until we receive a shutdown signal
-	token := <-shutdownChan
-	log.Log("Authenticated...")
-	_ = server.Shutdown(ctx)
-	return newTokenSourceWithRefreshCheck(ctx, token, cfg), nil
-}
-
-type TokenSourceWithRefreshCheck struct {
-	source      oauth2.TokenSource
-	checkPeriod time.Duration
-	lastToken   *oauth2.Token
-	done        chan struct{}
-	cancel      context.CancelFunc
-	cfg         *types.Config
-}
+	// Open URL in browser
+	log.Log("Opening URL: " + authURL)
+	openBrowser(authURL)
 
-func newTokenSourceWithRefreshCheck(ctx context.Context, token *oauth2.Token, cfg *types.Config) oauth2.TokenSource {
-	_, cancel := context.WithCancel(ctx)
-	ts := &TokenSourceWithRefreshCheck{
-		checkPeriod: 10 * time.Minute,
-		source:      oauthConfig.TokenSource(ctx, token),
-		cfg:         cfg,
-		done:        make(chan struct{}),
-		cancel:      cancel,
+	log.Log("Waiting for authentication...")
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-results:
+		return res.token, res.err
 	}
+}
 
-	if cfg.TokenCheck {
-		// Start periodic check
-		go ts.periodicCheck(ctx)
+// headlessLogin prints the auth URL and reads the redirect URL or the code from stdin.
+func (f *oauthFlow) headlessLogin(ctx context.Context) (*oauth2.Token, error) {
+	port, err := freeport.GetFreePort()
+	if err != nil {
+		return nil, err
 	}
-	return ts
-}
+	authURL := f.authCodeURL(port)
 
-func (ts *TokenSourceWithRefreshCheck) periodicCheck(ctx context.Context) {
-	ticker := time.NewTicker(ts.checkPeriod)
-	defer ticker.Stop()
+	log.Log("Open the following URL in a browser on any machine and sign in:")
+	log.Log(authURL)
+	log.Log("After signing in, the browser is redirected to a localhost URL that fails to load.")
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ts.done:
-			return
-		case <-ticker.C:
-			token, err := ts.source.Token()
-			if err != nil {
-				continue
-			}
+	input := make(chan string, 1)
+	go func() {
+		input <- stringPrompt("Paste the full URL from the address bar (or the code):")
+	}()
 
-			if ts.lastToken == nil || ts.lastToken.AccessToken != token.AccessToken {
-				_ = ts.cfg.SetToken(*token)
-				ts.lastToken = token
-			}
+	var code string
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case in := <-input:
+		code, err = parseAuthCode(in, f.state)
+		if err != nil {
+			return nil, err
 		}
 	}
-}
 
-func (ts *TokenSourceWithRefreshCheck) Token() (*oauth2.Token, error) {
-	token, err := ts.source.Token()
+	token, err := f.exchange(ctx, code)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("oauth exchange error: %w", err)
+	}
+	return token, nil
+}
+
+// parseCallback verifies the state of the callback query and returns the code.
+func (f *oauthFlow) parseCallback(query url.Values) (string, error) {
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(f.state)) != 1 {
+		return "", errors.New("invalid OAuth state")
 	}
+	if e := query.Get("error"); e != "" {
+		return "", fmt.Errorf("authorization failed: %s", e)
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", errors.New("missing code")
+	}
+	return code, nil
+}
 
-	// Also check for refresh during direct Token() calls
-	if ts.lastToken == nil || ts.lastToken.AccessToken != token.AccessToken {
-		_ = ts.cfg.SetToken(*token)
-		ts.lastToken = token
+// parseAuthCode extracts the code from a pasted redirect URL or returns the input as code.
+// The state of a pasted redirect URL must match the state of the login.
+func parseAuthCode(input, state string) (string, error) {
+	if input == "" {
+		return "", errors.New("no authorization code provided")
+	}
+	if !strings.Contains(input, "://") {
+		return input, nil
 	}
 
-	return token, nil
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect URL: %w", err)
+	}
+	return (&oauthFlow{state: state}).parseCallback(u.Query())
 }
 
-// Stop stops the periodic check.
-func (ts *TokenSourceWithRefreshCheck) Stop() {
-	ts.cancel()
-	close(ts.done)
+// exchange exchanges the authorization code for a token using the PKCE verifier.
+func (f *oauthFlow) exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	opts := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("code_verifier", f.codeVerifier)}
+	if f.config.ClientSecret != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("client_secret", f.config.ClientSecret))
+	}
+	return f.config.Exchange(ctx, code, opts...)
 }
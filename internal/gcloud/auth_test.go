@@ -0,0 +1,163 @@
+package gcloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/bakito/gws/internal/types"
+)
+
+var _ = Describe("Auth", func() {
+	Context("parseAuthCode", func() {
+		It("should return a pasted code", func() {
+			code, err := parseAuthCode("4/0Ab-code", "state")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(code).Should(Equal("4/0Ab-code"))
+		})
+
+		It("should extract the code from a redirect URL", func() {
+			code, err := parseAuthCode("http://localhost:1234/callback?state=state&code=4%2F0Ab-code&scope=openid", "state")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(code).Should(Equal("4/0Ab-code"))
+		})
+
+		It("should fail if the redirect URL contains an error", func() {
+			_, err := parseAuthCode("http://localhost:1234/callback?state=state&error=access_denied", "state")
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should fail if the redirect URL contains no code", func() {
+			_, err := parseAuthCode("http://localhost:1234/callback?state=state", "state")
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should fail if the state of the redirect URL does not match", func() {
+			_, err := parseAuthCode("http://localhost:1234/callback?state=other&code=4%2F0Ab-code", "state")
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should fail on empty input", func() {
+			_, err := parseAuthCode("", "state")
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("oauthConfig", func() {
+		It("should fall back to the embedded client", func() {
+			conf := oauthConfig(&types.Config{})
+			Ω(conf.ClientID).Should(Equal(clientID))
+			Ω(conf.ClientSecret).Should(Equal(clientSecret))
+			Ω(conf.Scopes).Should(Equal(defaultScopes))
+		})
+
+		It("should use the configured client with env overrides", func() {
+			GinkgoT().Setenv(types.OAuthClientIDEnv, "env-id")
+			GinkgoT().Setenv(types.OAuthScopesEnv, "openid, email")
+			conf := oauthConfig(&types.Config{OAuth: &types.OAuthClient{
+				ClientID: "config-id",
+				TokenURL: "https://sso.example.com/token",
+			}})
+			Ω(conf.ClientID).Should(Equal("env-id"))
+			Ω(conf.ClientSecret).Should(BeEmpty())
+			Ω(conf.Scopes).Should(Equal([]string{"openid", "email"}))
+			Ω(conf.Endpoint.TokenURL).Should(Equal("https://sso.example.com/token"))
+			Ω(conf.Endpoint.AuthURL).Should(Equal(google.Endpoint.AuthURL))
+		})
+	})
+
+	Context("Revoke", func() {
+		var (
+			server   *httptest.Server
+			revoked  string
+			orgURL   string
+			cfg      *types.Config
+			homeDir  string
+			tokenErr error
+		)
+		BeforeEach(func() {
+			homeDir = GinkgoT().TempDir()
+			GinkgoT().Setenv("HOME", homeDir)
+			GinkgoT().Setenv("USERPROFILE", homeDir)
+
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = r.ParseForm()
+				revoked = r.Form.Get("token")
+				if revoked == "invalid" {
+					http.Error(w, `{"error": "invalid_token"}`, http.StatusBadRequest)
+				}
+			}))
+			orgURL = RevokeURL
+			RevokeURL = server.URL
+
+			cfg = &types.Config{Tokens: &types.Tokens{}}
+			cfg.Tokens.Set("me@example.com", &types.TokenStorage{Token: oauth2.Token{
+				AccessToken:  "access",
+				RefreshToken: "refresh",
+			}})
+			cfg.Tokens.Set("other@example.com", &types.TokenStorage{Token: oauth2.Token{
+				AccessToken:  "other-access",
+				RefreshToken: "other-refresh",
+			}})
+			tokenErr = types.SaveTokens(*cfg.Tokens)
+		})
+		AfterEach(func() {
+			RevokeURL = orgURL
+			server.Close()
+		})
+
+		It("should revoke the refresh token and delete the stored token of the account", func() {
+			Ω(tokenErr).ShouldNot(HaveOccurred())
+			Ω(Revoke(context.Background(), cfg, "me@example.com")).ShouldNot(HaveOccurred())
+			Ω(revoked).Should(Equal("refresh"))
+
+			tk, err := types.LoadTokens()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tk.AccountNames()).Should(Equal([]string{"other@example.com"}))
+			Ω(tk.Default).Should(Equal("other@example.com"))
+		})
+
+		It("should revoke and delete the token of the default account", func() {
+			Ω(Revoke(context.Background(), cfg, "")).ShouldNot(HaveOccurred())
+			Ω(revoked).Should(Equal("refresh"))
+
+			tk, err := types.LoadTokens()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tk.AccountNames()).Should(Equal([]string{"other@example.com"}))
+		})
+
+		It("should only delete the token of a legacy token without account", func() {
+			cfg.Tokens.Set("", &types.TokenStorage{Token: oauth2.Token{RefreshToken: "legacy-refresh"}})
+			Ω(types.SaveTokens(*cfg.Tokens)).ShouldNot(HaveOccurred())
+
+			Ω(logout(cfg, "")).ShouldNot(HaveOccurred())
+
+			tk, err := types.LoadTokens()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tk.AccountNames()).Should(Equal([]string{"me@example.com", "other@example.com"}))
+		})
+
+		It("should revoke and delete the tokens of all accounts", func() {
+			Ω(RevokeAll(context.Background(), cfg)).ShouldNot(HaveOccurred())
+			Ω(revoked).Should(Equal("other-refresh"))
+
+			tk, err := types.LoadTokens()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tk).Should(BeNil())
+		})
+
+		It("should keep the stored token if the revocation fails", func() {
+			cfg.Tokens.Get("me@example.com").Token.RefreshToken = "invalid"
+			Ω(Revoke(context.Background(), cfg, "me@example.com")).Should(HaveOccurred())
+
+			tk, err := types.LoadTokens()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tk.Get("me@example.com")).ShouldNot(BeNil())
+		})
+	})
+})
@@ -0,0 +1,103 @@
+package gcloud
+
+import (
+	"context"
+	"sync"
+
+	workstations "cloud.google.com/go/workstations/apiv1"
+	"cloud.google.com/go/workstations/apiv1/workstationspb"
+
+	"github.com/bakito/gws/internal/types"
+)
+
+// Action is a lifecycle operation on a workstation.
+type Action string
+
+const (
+	ActionStart   Action = "start"
+	ActionStop    Action = "stop"
+	ActionRestart Action = "restart"
+)
+
+// Bulk runs lifecycle operations on the workstations of several contexts.
+type Bulk struct {
+	cfg      *types.Config
+	clients  *clients
+	contexts map[string]*workstations.Client
+}
+
+// NewBulk logs in and creates the clients of the given contexts, contexts with the same auth config share a client.
+func NewBulk(ctx context.Context, cfg *types.Config, contexts []string) (*Bulk, error) {
+	b := &Bulk{cfg: cfg, clients: newClients(cfg), contexts: make(map[string]*workstations.Client)}
+	for _, name := range contexts {
+		sshContext := cfg.Contexts[name]
+		if sshContext == nil || sshContext.GCloud == nil {
+			continue
+		}
+		c, err := b.clients.get(ctx, sshContext)
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		b.contexts[name] = c
+	}
+	return b, nil
+}
+
+// Close closes the clients.
+func (b *Bulk) Close() {
+	b.clients.Close()
+}
+
+// Run runs the action on the workstations of the given contexts concurrently.
+// The progress of each context is reported to the progress func, the errors are returned per context.
+func (b *Bulk) Run(ctx context.Context, contexts []string, action Action, progress ProgressFunc) map[string]error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(map[string]error)
+	)
+
+	for _, name := range contexts {
+		wg.Go(func() {
+			r := &progressReporter{context: name, progress: progress}
+			if err := b.run(ctx, name, action, r); err != nil {
+				r.Logf("🚨 %v", err)
+				mu.Lock()
+				errs[name] = err
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return errs
+}
+
+func (b *Bulk) run(ctx context.Context, contextName string, action Action, r reporter) error {
+	c, ok := b.contexts[contextName]
+	if !ok {
+		return ErrNoGCloudConfig
+	}
+	sshContext := b.cfg.Contexts[contextName]
+
+	ws, err := c.GetWorkstation(ctx, &workstationspb.GetWorkstationRequest{
+		Name: sshContext.GCloud.WorkstationName(),
+	})
+	if err != nil {
+		return err
+	}
+
+	name := sshContext.GCloud.Name
+	timeout := b.cfg.WorkstationTimeout(sshContext)
+	switch action {
+	case ActionStart:
+		_, err = startWorkstation(ctx, c, ws, name, timeout, r)
+	case ActionStop:
+		_, err = stopWorkstation(ctx, c, ws, name, timeout, r)
+	case ActionRestart:
+		if ws, err = stopWorkstation(ctx, c, ws, name, timeout, r); err == nil {
+			_, err = startWorkstation(ctx, c, ws, name, timeout, r)
+		}
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package gcloud
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -10,17 +11,19 @@ import (
 
 	workstations "cloud.google.com/go/workstations/apiv1"
 	"cloud.google.com/go/workstations/apiv1/workstationspb"
-	"google.golang.org/api/option"
 
 	"github.com/bakito/gws/internal/log"
 	"github.com/bakito/gws/internal/spinner"
 	"github.com/bakito/gws/internal/types"
 )
 
-const (
-	pollInterval    = 10 * time.Second
-	maxPollAttempts = 10
-	defaultTimeout  = pollInterval * maxPollAttempts
+var (
+	// ErrAborted is returned when the user did not confirm an operation.
+	ErrAborted = errors.New("aborted")
+	// ErrConfirmationMismatch is returned when the given confirmation does not match.
+	ErrConfirmationMismatch = errors.New("confirmation does not match")
+	// ErrNoGCloudConfig is returned when the context has no gcloud config.
+	ErrNoGCloudConfig = errors.New("no gcloud config found")
 )
 
 func StartWorkstation(ctx context.Context, cfg *types.Config) error {
@@ -30,113 +33,45 @@ func StartWorkstation(ctx context.Context, cfg *types.Config) error {
 	}
 	defer c.Close()
 
-	start := time.Now()
-
-	switch ws.GetState() {
-	case workstationspb.Workstation_STATE_STOPPED:
-		op, err := c.StartWorkstation(ctx, &workstationspb.StartWorkstationRequest{Name: ws.GetName()})
-		if err != nil {
-			log.Logf("Error starting workstation: %v", err)
-			return err
-		}
-		spinny := spinner.Start(fmt.Sprintf(" Waiting for workstation %s to start...", sshContext.GCloud.Name))
-		defer spinny.Stop() // reset the terminal in case of a panic
-		_, err = op.Wait(ctx)
-		spinny.Stop()
-		if err != nil {
-			log.Logf("Error waiting for workstation to start: %v", err)
-			return err
-		}
-		log.Logf("Workstation started in %s %q", time.Since(start).String(), sshContext.GCloud.Name)
-	case workstationspb.Workstation_STATE_RUNNING:
-		log.Logf("Workstation running %q", sshContext.GCloud.Name)
-	case workstationspb.Workstation_STATE_STARTING:
-		spinny := spinner.Start(fmt.Sprintf(" Workstation %s is already starting ...", sshContext.GCloud.Name))
-		defer spinny.Stop() // reset the terminal in case of a panic
-
-		err = waitForWorkstationRunning(ctx, c, ws, defaultTimeout)
-		spinny.Stop()
-
-		if err != nil {
-			return err
-		}
-
-		if ws.GetState() == workstationspb.Workstation_STATE_RUNNING {
-			log.Logf("Workstation started in %s %q", time.Since(start).String(), sshContext.GCloud.Name)
-		} else {
-			log.Logf("Workstation is in unexpected state: %s", ws.GetState())
-		}
-	default:
-	}
-	return nil
+	r := &consoleReporter{}
+	defer r.Stop() // reset the terminal in case of a panic
+	_, err = startWorkstation(ctx, c, ws, sshContext.GCloud.Name, cfg.WorkstationTimeout(sshContext), r)
+	return err
 }
 
-// waitForWorkstationRunning polls the workstation status until it's running or timeout occurs.
-// Returns error if the workstation fails to reach in running state within the specified timeout.
-func waitForWorkstationRunning(
+func startWorkstation(
 	ctx context.Context,
 	c *workstations.Client,
 	ws *workstationspb.Workstation,
+	name string,
 	timeout time.Duration,
-) error {
-	ticker := time.NewTicker(pollInterval)
-	defer ticker.Stop()
-
-	timeoutCh := time.After(timeout)
-
-	for {
-		select {
-		case <-timeoutCh:
-			return fmt.Errorf("timeout waiting for workstation %s to start", ws.GetName())
-		case <-ticker.C:
-			updatedWs, err := c.GetWorkstation(ctx, &workstationspb.GetWorkstationRequest{Name: ws.GetName()})
-			if err != nil {
-				return fmt.Errorf("failed to get workstation status: %w", err)
-			}
-
-			if updatedWs.GetState() == workstationspb.Workstation_STATE_RUNNING {
-				*ws = workstationspb.Workstation{
-					Name:  updatedWs.GetName(),
-					State: updatedWs.GetState(),
-				}
-				return nil
-			}
-		case <-ctx.Done():
-			return ctx.Err()
-		}
-	}
+	r reporter,
+) (*workstationspb.Workstation, error) {
+	return reconcile(ctx, apiClient{c}, ws, name, workstationspb.Workstation_STATE_RUNNING, timeout, r)
 }
 
 func setup(ctx context.Context, cfg *types.Config) (*types.Context, *workstations.Client, *workstationspb.Workstation, error) {
-	sshContext := cfg.CurrentContext()
+	return setupContext(ctx, cfg, cfg.CurrentContext())
+}
+
+// setupContext creates the client for the context and gets its workstation.
+func setupContext(
+	ctx context.Context,
+	cfg *types.Config,
+	sshContext *types.Context,
+) (*types.Context, *workstations.Client, *workstationspb.Workstation, error) {
 	if sshContext.GCloud == nil {
-		log.Log("No gcloud config found")
-		return nil, nil, nil, nil
+		return nil, nil, nil, ErrNoGCloudConfig
 	}
-	// gcloud auth application-default login
-	// Default credentials: ${HOME}/.config/gcloud/application_default_credentials.json
-	tokenSource, err := Login(ctx, cfg)
+	c, err := newClient(ctx, cfg, sshContext)
 	if err != nil {
-		log.Logf("Error getting OAUTH token: %v", err)
 		return nil, nil, nil, err
 	}
 
-	c, err := workstations.NewClient(ctx, option.WithTokenSource(tokenSource))
-	if err != nil {
-		log.Logf("Error creating workstations client: %v", err)
-		return nil, nil, nil, err
-	}
-	wsName := fmt.Sprintf("projects/%s/locations/%s/workstationClusters/%s/workstationConfigs/%s/workstations/%s",
-		sshContext.GCloud.Project,
-		sshContext.GCloud.Region,
-		sshContext.GCloud.Cluster,
-		sshContext.GCloud.Config,
-		sshContext.GCloud.Name,
-	)
-
-	ws, err := c.GetWorkstation(ctx, &workstationspb.GetWorkstationRequest{Name: wsName})
+	ws, err := c.GetWorkstation(ctx, &workstationspb.GetWorkstationRequest{Name: sshContext.GCloud.WorkstationName()})
 	if err != nil {
 		log.Logf("Error getting workstation: %v", err)
+		closeIt(c)
 		return nil, nil, nil, err
 	}
 	return sshContext, c, ws, err
@@ -150,24 +85,40 @@ func StopWorkstation(ctx context.Context, cfg *types.Config) error {
 
 	defer c.Close()
 
-	if ws.GetState() != workstationspb.Workstation_STATE_STOPPED {
-		op, err := c.StopWorkstation(ctx, &workstationspb.StopWorkstationRequest{Name: ws.GetName()})
-		if err != nil {
-			log.Logf("Error stopping workstation: %v", err)
-			return err
-		}
-		spinny := spinner.Start(fmt.Sprintf(" Waiting for workstation %s to stop...", sshContext.GCloud.Name))
-		defer spinny.Stop() // reset the terminal in case of a panic
+	r := &consoleReporter{}
+	defer r.Stop() // reset the terminal in case of a panic
+	_, err = stopWorkstation(ctx, c, ws, sshContext.GCloud.Name, cfg.WorkstationTimeout(sshContext), r)
+	return err
+}
 
-		_, err = op.Wait(ctx)
-		if err != nil {
-			log.Logf("Error waiting for workstation to stop: %v", err)
-			return err
-		}
-		spinny.Stop()
+// RestartWorkstation stops and starts the workstation of the current context.
+func RestartWorkstation(ctx context.Context, cfg *types.Config) error {
+	sshContext, c, ws, err := setup(ctx, cfg)
+	if err != nil {
+		return err
 	}
-	log.Logf("Workstation stopped %q", sshContext.GCloud.Name)
-	return nil
+	defer c.Close()
+
+	r := &consoleReporter{}
+	defer r.Stop() // reset the terminal in case of a panic
+	timeout := cfg.WorkstationTimeout(sshContext)
+	ws, err = stopWorkstation(ctx, c, ws, sshContext.GCloud.Name, timeout, r)
+	if err != nil {
+		return err
+	}
+	_, err = startWorkstation(ctx, c, ws, sshContext.GCloud.Name, timeout, r)
+	return err
+}
+
+func stopWorkstation(
+	ctx context.Context,
+	c *workstations.Client,
+	ws *workstationspb.Workstation,
+	name string,
+	timeout time.Duration,
+	r reporter,
+) (*workstationspb.Workstation, error) {
+	return reconcile(ctx, apiClient{c}, ws, name, workstationspb.Workstation_STATE_STOPPED, timeout, r)
 }
 
 func stringPrompt(label string) string {
@@ -183,16 +134,20 @@ func stringPrompt(label string) string {
 	return strings.TrimSpace(s)
 }
 
-func DeleteWorkstation(ctx context.Context, cfg *types.Config) error {
-	name := stringPrompt(
-		fmt.Sprintf(
-			"Please confirm the deletion of workstation %q by entering the name again:",
-			cfg.CurrentContext().GCloud.Name,
-		),
-	)
-	if name != cfg.CurrentContext().GCloud.Name {
-		log.Log("Aborting ...")
-		return nil
+// DeleteWorkstation deletes the workstation of the current context.
+// If confirm is empty, the user is prompted to confirm the deletion by entering the workstation name.
+func DeleteWorkstation(ctx context.Context, cfg *types.Config, confirm string) error {
+	wsName := cfg.CurrentContext().GCloud.Name
+	if confirm != "" && confirm != wsName {
+		return fmt.Errorf("%w: %q is not the workstation name %q", ErrConfirmationMismatch, confirm, wsName)
+	}
+	if confirm == "" {
+		name := stringPrompt(
+			fmt.Sprintf("Please confirm the deletion of workstation %q by entering the name again:", wsName),
+		)
+		if name != wsName {
+			return ErrAborted
+		}
 	}
 
 	sshContext, c, ws, err := setup(ctx, cfg)
@@ -207,7 +162,7 @@ func DeleteWorkstation(ctx context.Context, cfg *types.Config) error {
 		log.Logf("Error deleting workstation: %v", err)
 		return err
 	}
-	spinny := spinner.Start(fmt.Sprintf(" Deleting workstation %s to stop...", sshContext.GCloud.Name))
+	spinny := spinner.Start(fmt.Sprintf(" Deleting workstation %s ...", sshContext.GCloud.Name))
 	defer spinny.Stop() // reset the terminal in case of a panic
 
 	_, err = op.Wait(ctx)
@@ -216,5 +171,6 @@ func DeleteWorkstation(ctx context.Context, cfg *types.Config) error {
 		return err
 	}
 	spinny.Stop()
+	log.Logf("Workstation deleted %q", sshContext.GCloud.Name)
 	return nil
 }
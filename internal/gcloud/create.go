@@ -0,0 +1,58 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/workstations/apiv1beta/workstationspb"
+
+	"github.com/bakito/gws/internal/log"
+	"github.com/bakito/gws/internal/spinner"
+	"github.com/bakito/gws/internal/types"
+)
+
+// CreateWorkstation creates the workstation defined by the gcloud config of the current context.
+// The v1beta API is used, as v1 does not support the env variables of a workstation.
+func CreateWorkstation(ctx context.Context, cfg *types.Config) error {
+	sshContext := cfg.CurrentContext()
+	if sshContext.GCloud == nil {
+		return ErrNoGCloudConfig
+	}
+
+	c, err := newBetaClient(ctx, cfg, sshContext)
+	if err != nil {
+		return err
+	}
+	defer closeIt(c)
+
+	ws := &workstationspb.Workstation{}
+	if spec := sshContext.Workstation; spec != nil {
+		ws.DisplayName = spec.DisplayName
+		ws.Labels = spec.Labels
+		ws.Annotations = spec.Annotations
+		ws.Env = spec.Env
+	}
+
+	start := time.Now()
+	op, err := c.CreateWorkstation(ctx, &workstationspb.CreateWorkstationRequest{
+		Parent:        sshContext.GCloud.ConfigName(),
+		WorkstationId: sshContext.GCloud.Name,
+		Workstation:   ws,
+	})
+	if err != nil {
+		log.Logf("Error creating workstation: %v", err)
+		return err
+	}
+	spinny := spinner.Start(fmt.Sprintf(" Waiting for workstation %s to be created...", sshContext.GCloud.Name))
+	defer spinny.Stop() // reset the terminal in case of a panic
+
+	_, err = op.Wait(ctx)
+	spinny.Stop()
+	if err != nil {
+		log.Logf("Error waiting for workstation to be created: %v", err)
+		return err
+	}
+	log.Logf("Workstation created in %s %q", time.Since(start).String(), sshContext.GCloud.Name)
+	return nil
+}
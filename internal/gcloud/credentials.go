@@ -0,0 +1,129 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	workstations "cloud.google.com/go/workstations/apiv1"
+	workstationsbeta "cloud.google.com/go/workstations/apiv1beta"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+
+	"github.com/bakito/gws/internal/env"
+	"github.com/bakito/gws/internal/log"
+	"github.com/bakito/gws/internal/types"
+)
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// tokenSource returns the token source for the auth config of the context.
+func tokenSource(ctx context.Context, cfg *types.Config, sshContext *types.Context) (oauth2.TokenSource, error) {
+	switch sshContext.AuthType() {
+	case types.AuthTypeOAuth:
+		return Login(ctx, cfg, sshContext.Account)
+	case types.AuthTypeADC:
+		creds, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find application default credentials: %w", err)
+		}
+		return creds.TokenSource, nil
+	case types.AuthTypeServiceAccount:
+		if sshContext.Auth.KeyFile == "" {
+			return nil, fmt.Errorf("auth type %q requires a keyFile", types.AuthTypeServiceAccount)
+		}
+		data, err := os.ReadFile(env.ExpandEnv(sshContext.Auth.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read service account key file: %w", err)
+		}
+		jwtConfig, err := google.JWTConfigFromJSON(data, cloudPlatformScope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse service account key file: %w", err)
+		}
+		return jwtConfig.TokenSource(ctx), nil
+	case types.AuthTypeImpersonate:
+		if sshContext.Auth.ServiceAccount == "" {
+			return nil, fmt.Errorf("auth type %q requires a serviceAccount", types.AuthTypeImpersonate)
+		}
+		base, err := Login(ctx, cfg, sshContext.Account)
+		if err != nil {
+			return nil, err
+		}
+		return impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: sshContext.Auth.ServiceAccount,
+			Scopes:          []string{cloudPlatformScope},
+			Delegates:       sshContext.Auth.Delegates,
+		}, option.WithTokenSource(base))
+	default:
+		return nil, fmt.Errorf("unknown auth type %q", sshContext.AuthType())
+	}
+}
+
+// clients caches the workstations clients of contexts sharing the same auth config.
+type clients struct {
+	cfg    *types.Config
+	byAuth map[string]*workstations.Client
+}
+
+func newClients(cfg *types.Config) *clients {
+	return &clients{cfg: cfg, byAuth: make(map[string]*workstations.Client)}
+}
+
+// get returns the client for the auth config of the context.
+func (cl *clients) get(ctx context.Context, sshContext *types.Context) (*workstations.Client, error) {
+	key := string(sshContext.AuthType())
+	if sshContext.Auth != nil {
+		key = fmt.Sprintf("%+v", *sshContext.Auth)
+	}
+	key += "/" + sshContext.Account
+	if c, ok := cl.byAuth[key]; ok {
+		return c, nil
+	}
+	c, err := newClient(ctx, cl.cfg, sshContext)
+	if err != nil {
+		return nil, err
+	}
+	cl.byAuth[key] = c
+	return c, nil
+}
+
+func (cl *clients) Close() {
+	for _, c := range cl.byAuth {
+		closeIt(c)
+	}
+}
+
+// newClient creates a new workstations client authenticated with the credentials of the context.
+func newClient(ctx context.Context, cfg *types.Config, sshContext *types.Context) (*workstations.Client, error) {
+	ts, err := tokenSource(ctx, cfg, sshContext)
+	if err != nil {
+		log.Logf("Error getting OAUTH token: %v", err)
+		return nil, err
+	}
+
+	c, err := workstations.NewClient(ctx, option.WithTokenSource(ts))
+	if err != nil {
+		log.Logf("Error creating workstations client: %v", err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// newBetaClient creates a new workstations client of the v1beta API authenticated with the credentials of the context.
+// The beta API is only used for features not available in v1, like the env variables of a workstation.
+func newBetaClient(ctx context.Context, cfg *types.Config, sshContext *types.Context) (*workstationsbeta.Client, error) {
+	ts, err := tokenSource(ctx, cfg, sshContext)
+	if err != nil {
+		log.Logf("Error getting OAUTH token: %v", err)
+		return nil, err
+	}
+
+	c, err := workstationsbeta.NewClient(ctx, option.WithTokenSource(ts))
+	if err != nil {
+		log.Logf("Error creating workstations client: %v", err)
+		return nil, err
+	}
+	return c, nil
+}
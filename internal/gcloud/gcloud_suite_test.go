@@ -0,0 +1,13 @@
+package gcloud
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestGCloud(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "GCloud Suite")
+}
@@ -0,0 +1,123 @@
+package gcloud
+
+import (
+	"context"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	workstations "cloud.google.com/go/workstations/apiv1"
+	"cloud.google.com/go/workstations/apiv1/workstationspb"
+
+	"github.com/bakito/gws/internal/types"
+)
+
+// WorkstationInfo summarizes a workstation and its live state.
+type WorkstationInfo struct {
+	Context   string
+	Name      string
+	Cluster   string
+	Config    string
+	State     workstationspb.Workstation_State
+	Host      string
+	StartTime time.Time
+}
+
+// StateName returns the state of the workstation without the STATE_ prefix.
+func (i WorkstationInfo) StateName() string {
+	return stateName(i.State)
+}
+
+// ListWorkstations walks all clusters, configs and workstations in the project and region of the current context.
+// If allContexts is set, the project and region of every configured context are walked.
+func ListWorkstations(ctx context.Context, cfg *types.Config, allContexts bool) ([]WorkstationInfo, error) {
+	// the contexts used to access the locations
+	locations := make(map[string]*types.Context)
+	if allContexts {
+		for _, name := range cfg.ContextNames() {
+			c := cfg.Contexts[name]
+			if c.GCloud != nil {
+				if _, ok := locations[c.GCloud.Location()]; !ok {
+					locations[c.GCloud.Location()] = c
+				}
+			}
+		}
+	} else {
+		sshContext := cfg.CurrentContext()
+		if sshContext == nil || sshContext.GCloud == nil {
+			return nil, ErrNoGCloudConfig
+		}
+		locations[sshContext.GCloud.Location()] = sshContext
+	}
+
+	// map the workstation resource names to the contexts using them
+	contextNames := make(map[string]string)
+	for name, c := range cfg.Contexts {
+		if c.GCloud != nil {
+			contextNames[c.GCloud.WorkstationName()] = name
+		}
+	}
+
+	cl := newClients(cfg)
+	defer cl.Close()
+
+	var infos []WorkstationInfo
+	for _, location := range slices.Sorted(maps.Keys(locations)) {
+		c, err := cl.get(ctx, locations[location])
+		if err != nil {
+			return nil, err
+		}
+		li, err := listLocation(ctx, c, location, contextNames)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, li...)
+	}
+	return infos, nil
+}
+
+func listLocation(
+	ctx context.Context,
+	c *workstations.Client,
+	location string,
+	contextNames map[string]string,
+) ([]WorkstationInfo, error) {
+	var infos []WorkstationInfo
+	clusters := c.ListWorkstationClusters(ctx, &workstationspb.ListWorkstationClustersRequest{Parent: location})
+	for cluster, err := range clusters.All() {
+		if err != nil {
+			return nil, err
+		}
+		configs := c.ListWorkstationConfigs(ctx, &workstationspb.ListWorkstationConfigsRequest{Parent: cluster.GetName()})
+		for config, err := range configs.All() {
+			if err != nil {
+				return nil, err
+			}
+			wss := c.ListWorkstations(ctx, &workstationspb.ListWorkstationsRequest{Parent: config.GetName()})
+			for ws, err := range wss.All() {
+				if err != nil {
+					return nil, err
+				}
+				info := WorkstationInfo{
+					Context: contextNames[ws.GetName()],
+					Name:    path.Base(ws.GetName()),
+					Cluster: path.Base(cluster.GetName()),
+					Config:  path.Base(config.GetName()),
+					State:   ws.GetState(),
+					Host:    ws.GetHost(),
+				}
+				if ws.GetStartTime() != nil {
+					info.StartTime = ws.GetStartTime().AsTime()
+				}
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos, nil
+}
+
+func stateName(state workstationspb.Workstation_State) string {
+	return strings.TrimPrefix(state.String(), "STATE_")
+}
@@ -0,0 +1,169 @@
+package gcloud
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectionStats the traffic of a tunnel connection.
+type ConnectionStats struct {
+	ID         int64
+	ClientAddr string
+	RemotePort int
+	Start      time.Time
+	// BytesIn the bytes received from the workstation.
+	BytesIn int64
+	// BytesOut the bytes sent to the workstation.
+	BytesOut int64
+	// InRate the bytes per second received from the workstation since the last snapshot.
+	InRate float64
+	// OutRate the bytes per second sent to the workstation since the last snapshot.
+	OutRate float64
+	// Latency the last websocket round-trip time.
+	Latency time.Duration
+}
+
+// TunnelStats the traffic of all connections of a tunnel.
+type TunnelStats struct {
+	Connections []ConnectionStats
+	// Total the number of all accepted connections, including the closed ones.
+	Total    int64
+	BytesIn  int64
+	BytesOut int64
+	InRate   float64
+	OutRate  float64
+	// Latency the last websocket round-trip time of any connection.
+	Latency time.Duration
+}
+
+// TunnelMetrics collects the traffic of the tunnel connections.
+type TunnelMetrics struct {
+	mu          sync.Mutex
+	nextID      int64
+	connections map[int64]*connMetrics
+	// closed traffic of the closed connections
+	closedIn  int64
+	closedOut int64
+	latency   time.Duration
+	lastIn    int64
+	lastOut   int64
+	lastTime  time.Time
+}
+
+// NewTunnelMetrics creates new tunnel metrics.
+func NewTunnelMetrics() *TunnelMetrics {
+	return &TunnelMetrics{connections: make(map[int64]*connMetrics), lastTime: time.Now()}
+}
+
+// connMetrics the counters of a connection.
+type connMetrics struct {
+	id         int64
+	clientAddr string
+	remotePort int
+	start      time.Time
+	in         atomic.Int64
+	out        atomic.Int64
+	latency    atomic.Int64
+	lastIn     int64
+	lastOut    int64
+	lastTime   time.Time
+}
+
+// add registers a new connection, nil metrics return nil counters.
+func (m *TunnelMetrics) add(clientAddr string, remotePort int) *connMetrics {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	now := time.Now()
+	c := &connMetrics{id: m.nextID, clientAddr: clientAddr, remotePort: remotePort, start: now, lastTime: now}
+	m.connections[c.id] = c
+	return c
+}
+
+// remove unregisters the connection and keeps its traffic in the totals.
+func (m *TunnelMetrics) remove(c *connMetrics) {
+	if m == nil || c == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.connections, c.id)
+	m.closedIn += c.in.Load()
+	m.closedOut += c.out.Load()
+}
+
+// setLatency records the websocket round-trip time of the connection.
+func (m *TunnelMetrics) setLatency(c *connMetrics, latency time.Duration) {
+	if m == nil || c == nil {
+		return
+	}
+	c.latency.Store(int64(latency))
+	m.mu.Lock()
+	m.latency = latency
+	m.mu.Unlock()
+}
+
+// Snapshot returns the current stats, the rates are calculated since the last snapshot.
+func (m *TunnelMetrics) Snapshot() TunnelStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	stats := TunnelStats{
+		Total:    m.nextID,
+		BytesIn:  m.closedIn,
+		BytesOut: m.closedOut,
+		Latency:  m.latency,
+	}
+	for _, c := range m.connections {
+		in, out := c.in.Load(), c.out.Load()
+		cs := ConnectionStats{
+			ID:         c.id,
+			ClientAddr: c.clientAddr,
+			RemotePort: c.remotePort,
+			Start:      c.start,
+			BytesIn:    in,
+			BytesOut:   out,
+			InRate:     rate(in-c.lastIn, now.Sub(c.lastTime)),
+			OutRate:    rate(out-c.lastOut, now.Sub(c.lastTime)),
+			Latency:    time.Duration(c.latency.Load()),
+		}
+		c.lastIn, c.lastOut, c.lastTime = in, out, now
+
+		stats.Connections = append(stats.Connections, cs)
+		stats.BytesIn += in
+		stats.BytesOut += out
+	}
+	stats.InRate = rate(stats.BytesIn-m.lastIn, now.Sub(m.lastTime))
+	stats.OutRate = rate(stats.BytesOut-m.lastOut, now.Sub(m.lastTime))
+	m.lastIn, m.lastOut, m.lastTime = stats.BytesIn, stats.BytesOut, now
+
+	slices.SortFunc(stats.Connections, func(a, b ConnectionStats) int { return int(a.ID - b.ID) })
+	return stats
+}
+
+func rate(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / d.Seconds()
+}
+
+func (c *connMetrics) addIn(n int) {
+	if c != nil {
+		c.in.Add(int64(n))
+	}
+}
+
+func (c *connMetrics) addOut(n int) {
+	if c != nil {
+		c.out.Add(int64(n))
+	}
+}
@@ -0,0 +1,42 @@
+package gcloud
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TunnelMetrics", func() {
+	It("should count the traffic of open and closed connections", func() {
+		m := NewTunnelMetrics()
+		c1 := m.add("127.0.0.1:50001", 22)
+		c2 := m.add("127.0.0.1:50002", 5432)
+		c1.addIn(100)
+		c1.addOut(10)
+		c2.addIn(50)
+		m.setLatency(c2, 20*time.Millisecond)
+
+		stats := m.Snapshot()
+		Ω(stats.Connections).Should(HaveLen(2))
+		Ω(stats.Connections[0].ClientAddr).Should(Equal("127.0.0.1:50001"))
+		Ω(stats.Connections[1].Latency).Should(Equal(20 * time.Millisecond))
+		Ω(stats.BytesIn).Should(Equal(int64(150)))
+		Ω(stats.BytesOut).Should(Equal(int64(10)))
+		Ω(stats.InRate).Should(BeNumerically(">", 0))
+
+		m.remove(c1)
+		stats = m.Snapshot()
+		Ω(stats.Connections).Should(HaveLen(1))
+		Ω(stats.Total).Should(Equal(int64(2)))
+		Ω(stats.BytesIn).Should(Equal(int64(150)))
+		Ω(stats.InRate).Should(BeZero())
+	})
+
+	It("should ignore nil metrics", func() {
+		var m *TunnelMetrics
+		c := m.add("127.0.0.1:50001", 22)
+		c.addIn(1)
+		m.remove(c)
+	})
+})
@@ -0,0 +1,63 @@
+package gcloud
+
+import (
+	"context"
+	"io"
+
+	"github.com/bakito/gws/internal/log"
+	"github.com/bakito/gws/internal/types"
+)
+
+// stdio combines a reader and a writer, e.g. stdin and stdout.
+type stdio struct {
+	io.Reader
+	io.Writer
+}
+
+// CloseWrite closes the writer to signal the end of the output.
+func (s stdio) CloseWrite() error {
+	if c, ok := s.Writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Proxy bridges in and out to the ssh port of the workstation of the context, without a local listener.
+// The workstation is started if it is not running. Progress is reported with the logger only.
+func Proxy(ctx context.Context, cfg *types.Config, contextName string, in io.Reader, out io.Writer) error {
+	sshContext, err := cfg.Context(contextName)
+	if err != nil {
+		return err
+	}
+	sshContext, c, ws, err := setupContext(ctx, cfg, sshContext)
+	if err != nil {
+		return err
+	}
+	defer closeIt(c)
+
+	r := &progressReporter{
+		context:  contextName,
+		progress: func(_, msg string, _ bool) { log.Log(msg) },
+	}
+	ws, err = startWorkstation(ctx, c, ws, sshContext.GCloud.Name, cfg.WorkstationTimeout(sshContext), r)
+	if err != nil {
+		return err
+	}
+
+	t := newTunnel(cfg, c, ws)
+	t.restart = true
+	t.timeout = cfg.WorkstationTimeout(sshContext)
+	if err := t.setAuthToken(ctx); err != nil {
+		return err
+	}
+
+	wsConn, err := t.connectWebsocket(ctx, sshPort)
+	if err != nil {
+		return err
+	}
+	defer closeIt(wsConn)
+
+	log.Logf("🕳️ Proxying to workstation %s", sshContext.GCloud.Name)
+	t.bridge(stdio{Reader: in, Writer: out}, wsConn, nil)
+	return nil
+}
@@ -0,0 +1,138 @@
+package gcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"cloud.google.com/go/workstations/apiv1/workstationspb"
+
+	"github.com/bakito/gws/internal/ssh"
+	"github.com/bakito/gws/internal/types"
+)
+
+// readinessRetryInterval the interval of the ssh and readiness command attempts.
+var readinessRetryInterval = 2 * time.Second
+
+// WaitForSSH waits until the sshd of the running workstation of the current context answers
+// through an ephemeral tunnel, and the readiness commands of the context succeed.
+func WaitForSSH(ctx context.Context, cfg *types.Config) error {
+	sshContext, c, ws, err := setup(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeIt(c)
+
+	if ws.GetState() != workstationspb.Workstation_STATE_RUNNING {
+		return fmt.Errorf("workstation %s is not running but %s", sshContext.GCloud.Name, stateName(ws.GetState()))
+	}
+
+	timeout := cfg.WorkstationTimeout(sshContext)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	t := newTunnel(cfg, c, ws)
+	// the readiness check retries itself
+	t.attempts = 1
+	if err := t.setAuthToken(ctx); err != nil {
+		return err
+	}
+
+	lc := net.ListenConfig{}
+	listener, err := lc.Listen(ctx, "tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	defer closeIt(listener)
+
+	go func() {
+		for {
+			clientConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go t.handleConnection(ctx, clientConn, sshPort)
+		}
+	}()
+
+	r := &consoleReporter{}
+	defer r.Stop() // reset the terminal in case of a panic
+
+	start := time.Now()
+	addr := listener.Addr().String()
+	r.Waiting("Waiting for ssh of workstation %s to answer...", sshContext.GCloud.Name)
+	err = retry(ctx, func() error {
+		_, err := ssh.GetHostKey(addr, cfg.SSHTimeout())
+		return err
+	})
+	if err != nil {
+		r.Stop()
+		return readinessError(ctx, "ssh", sshContext, timeout, err)
+	}
+	r.Logf("🔑 SSH of workstation %q is answering after %s", sshContext.GCloud.Name, time.Since(start).String())
+
+	if len(sshContext.ReadinessCommands) > 0 {
+		r.Waiting("Running readiness commands on workstation %s...", sshContext.GCloud.Name)
+		// the commands are polled, e.g. until a service is up or the home directory is mounted
+		err = retry(ctx, func() error {
+			return readinessAttempt(addr, sshContext, cfg.SSHTimeout())
+		})
+		if err != nil {
+			r.Stop()
+			return readinessError(ctx, "readiness commands", sshContext, timeout, err)
+		}
+	}
+
+	r.Logf("✅ Workstation ready in %s %q", time.Since(start).String(), sshContext.GCloud.Name)
+	return nil
+}
+
+// readinessAttempt connects to the ssh of the workstation and runs the readiness commands once.
+func readinessAttempt(addr string, sshContext *types.Context, timeout time.Duration) error {
+	cl, err := ssh.NewClient(addr, sshContext.User, sshContext.PrivateKeyFile, timeout)
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	return runReadinessCommands(cl, sshContext.ReadinessCommands)
+}
+
+// commandExecutor executes a command on the workstation.
+type commandExecutor interface {
+	Execute(command string) (string, error)
+}
+
+// runReadinessCommands runs the commands until the first one fails, its error contains the command output.
+func runReadinessCommands(cl commandExecutor, commands []string) error {
+	for _, command := range commands {
+		if _, err := cl.Execute(command); err != nil {
+			return fmt.Errorf("readiness command %q failed: %w", command, err)
+		}
+	}
+	return nil
+}
+
+// retry calls fn until it succeeds or the context is done, the last error is returned.
+func retry(ctx context.Context, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(readinessRetryInterval):
+		}
+	}
+}
+
+func readinessError(ctx context.Context, what string, sshContext *types.Context, timeout time.Duration, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timeout after %s waiting for %s of workstation %s: %w", timeout, what, sshContext.GCloud.Name, err)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package gcloud
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeExecutor fails the commands until the given number of attempts.
+type fakeExecutor struct {
+	failures int
+	calls    int
+}
+
+func (e *fakeExecutor) Execute(string) (string, error) {
+	e.calls++
+	if e.calls <= e.failures {
+		return "", errors.New("failed to execute command: exit status 1: home not mounted")
+	}
+	return "ok", nil
+}
+
+var _ = Describe("readiness", func() {
+	var orgInterval time.Duration
+	BeforeEach(func() {
+		orgInterval = readinessRetryInterval
+		readinessRetryInterval = 5 * time.Millisecond
+	})
+	AfterEach(func() {
+		readinessRetryInterval = orgInterval
+	})
+
+	It("should retry a readiness command until it succeeds", func() {
+		e := &fakeExecutor{failures: 1}
+		err := retry(context.Background(), func() error {
+			return runReadinessCommands(e, []string{"test -d /home/user"})
+		})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(e.calls).Should(Equal(2))
+	})
+
+	It("should return the output of the last failed command once the context is done", func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		e := &fakeExecutor{failures: 1000}
+		err := retry(ctx, func() error {
+			return runReadinessCommands(e, []string{"test -d /home/user"})
+		})
+		Ω(err).Should(MatchError(ContainSubstring(`readiness command "test -d /home/user" failed`)))
+		Ω(err).Should(MatchError(ContainSubstring("home not mounted")))
+		Ω(e.calls).Should(BeNumerically(">", 1))
+	})
+})
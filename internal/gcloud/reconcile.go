@@ -0,0 +1,165 @@
+package gcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	workstations "cloud.google.com/go/workstations/apiv1"
+	"cloud.google.com/go/workstations/apiv1/workstationspb"
+)
+
+// pollInterval the interval the state of a workstation in a transitional state is polled with.
+var pollInterval = 5 * time.Second
+
+// stateClient changes and gets the state of workstations.
+type stateClient interface {
+	// get returns the workstation.
+	get(ctx context.Context, name string) (*workstationspb.Workstation, error)
+	// start starts the workstation and waits for the operation to complete.
+	start(ctx context.Context, name string) (*workstationspb.Workstation, error)
+	// stop stops the workstation and waits for the operation to complete.
+	stop(ctx context.Context, name string) (*workstationspb.Workstation, error)
+}
+
+// apiClient changes the state of workstations with the workstations API.
+type apiClient struct {
+	*workstations.Client
+}
+
+func (c apiClient) get(ctx context.Context, name string) (*workstationspb.Workstation, error) {
+	return c.GetWorkstation(ctx, &workstationspb.GetWorkstationRequest{Name: name})
+}
+
+func (c apiClient) start(ctx context.Context, name string) (*workstationspb.Workstation, error) {
+	op, err := c.StartWorkstation(ctx, &workstationspb.StartWorkstationRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return op.Wait(ctx)
+}
+
+func (c apiClient) stop(ctx context.Context, name string) (*workstationspb.Workstation, error) {
+	op, err := c.StopWorkstation(ctx, &workstationspb.StopWorkstationRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	return op.Wait(ctx)
+}
+
+// reconcile drives the workstation to the desired state. Transitional states are waited out
+// before the next operation is triggered, until the desired state is reached or the timeout elapses.
+func reconcile(
+	ctx context.Context,
+	c stateClient,
+	ws *workstationspb.Workstation,
+	name string,
+	desired workstationspb.Workstation_State,
+	timeout time.Duration,
+	r reporter,
+) (*workstationspb.Workstation, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	changed := false
+	last := ws.GetState()
+	for {
+		state := ws.GetState()
+		if state != last {
+			r.Logf("🔄 Workstation %q changed from %s to %s", name, stateName(last), stateName(state))
+			last = state
+			changed = true
+		}
+
+		if state == desired {
+			switch {
+			case desired == workstationspb.Workstation_STATE_STOPPED:
+				r.Logf("Workstation stopped %q", name)
+			case changed:
+				r.Logf("Workstation started in %s %q", time.Since(start).String(), name)
+			default:
+				r.Logf("Workstation running %q", name)
+			}
+			return ws, nil
+		}
+
+		var err error
+		switch state {
+		case workstationspb.Workstation_STATE_STOPPED:
+			r.Waiting("Waiting for workstation %s to start...", name)
+			ws, err = startOperation(ctx, c, ws)
+		case workstationspb.Workstation_STATE_RUNNING:
+			r.Waiting("Waiting for workstation %s to stop...", name)
+			ws, err = stopOperation(ctx, c, ws)
+		case workstationspb.Workstation_STATE_STARTING:
+			r.Waiting("Workstation %s is starting ...", name)
+			ws, err = waitForStateChange(ctx, c, ws)
+		case workstationspb.Workstation_STATE_STOPPING:
+			r.Waiting("Workstation %s is stopping ...", name)
+			ws, err = waitForStateChange(ctx, c, ws)
+		default:
+			r.Waiting("Workstation %s is in state %s ...", name, stateName(state))
+			ws, err = waitForStateChange(ctx, c, ws)
+		}
+		r.Stop()
+
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("timeout after %s waiting for workstation %s to be %s",
+					timeout, name, stateName(desired))
+			}
+			return nil, err
+		}
+	}
+}
+
+func startOperation(
+	ctx context.Context,
+	c stateClient,
+	ws *workstationspb.Workstation,
+) (*workstationspb.Workstation, error) {
+	ws, err := c.start(ctx, ws.GetName())
+	if err != nil {
+		return nil, fmt.Errorf("error starting workstation: %w", err)
+	}
+	return ws, nil
+}
+
+func stopOperation(
+	ctx context.Context,
+	c stateClient,
+	ws *workstationspb.Workstation,
+) (*workstationspb.Workstation, error) {
+	ws, err := c.stop(ctx, ws.GetName())
+	if err != nil {
+		return nil, fmt.Errorf("error stopping workstation: %w", err)
+	}
+	return ws, nil
+}
+
+// waitForStateChange polls the workstation until its state differs from the current state.
+func waitForStateChange(
+	ctx context.Context,
+	c stateClient,
+	ws *workstationspb.Workstation,
+) (*workstationspb.Workstation, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			updatedWs, err := c.get(ctx, ws.GetName())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get workstation status: %w", err)
+			}
+			if updatedWs.GetState() != ws.GetState() {
+				return updatedWs, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
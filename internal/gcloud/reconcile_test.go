@@ -0,0 +1,82 @@
+package gcloud
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/workstations/apiv1/workstationspb"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	stopped  = workstationspb.Workstation_STATE_STOPPED
+	starting = workstationspb.Workstation_STATE_STARTING
+	running  = workstationspb.Workstation_STATE_RUNNING
+	stopping = workstationspb.Workstation_STATE_STOPPING
+)
+
+// fakeStateClient returns the polled states in order and completes start and stop operations immediately.
+type fakeStateClient struct {
+	polled []workstationspb.Workstation_State
+	calls  []string
+}
+
+func (c *fakeStateClient) get(_ context.Context, name string) (*workstationspb.Workstation, error) {
+	c.calls = append(c.calls, "get")
+	state := c.polled[0]
+	if len(c.polled) > 1 {
+		c.polled = c.polled[1:]
+	}
+	return &workstationspb.Workstation{Name: name, State: state}, nil
+}
+
+func (c *fakeStateClient) start(_ context.Context, name string) (*workstationspb.Workstation, error) {
+	c.calls = append(c.calls, "start")
+	return &workstationspb.Workstation{Name: name, State: running}, nil
+}
+
+func (c *fakeStateClient) stop(_ context.Context, name string) (*workstationspb.Workstation, error) {
+	c.calls = append(c.calls, "stop")
+	return &workstationspb.Workstation{Name: name, State: stopped}, nil
+}
+
+var _ = Describe("reconcile", func() {
+	var orgInterval time.Duration
+	BeforeEach(func() {
+		orgInterval = pollInterval
+		pollInterval = 5 * time.Millisecond
+	})
+	AfterEach(func() {
+		pollInterval = orgInterval
+	})
+
+	DescribeTable("should drive the workstation to the desired state",
+		func(state, desired workstationspb.Workstation_State, polled []workstationspb.Workstation_State, calls []string) {
+			c := &fakeStateClient{polled: polled}
+			r := &progressReporter{progress: func(string, string, bool) {}}
+
+			ws, err := reconcile(context.Background(), c, &workstationspb.Workstation{State: state}, "ws", desired,
+				time.Second, r)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ws.GetState()).Should(Equal(desired))
+			Ω(c.calls).Should(Equal(calls))
+		},
+		Entry("start while stopping", stopping, running, []workstationspb.Workstation_State{stopping, stopped},
+			[]string{"get", "get", "start"}),
+		Entry("stop while starting", starting, stopped, []workstationspb.Workstation_State{running},
+			[]string{"get", "stop"}),
+		Entry("already running", running, running, nil, nil),
+		Entry("already stopped", stopped, stopped, nil, nil),
+	)
+
+	It("should fail if the desired state is not reached within the timeout", func() {
+		c := &fakeStateClient{polled: []workstationspb.Workstation_State{starting}}
+		r := &progressReporter{progress: func(string, string, bool) {}}
+
+		_, err := reconcile(context.Background(), c, &workstationspb.Workstation{State: starting}, "ws", running,
+			50*time.Millisecond, r)
+		Ω(err).Should(MatchError(ContainSubstring("timeout after 50ms")))
+		Ω(c.calls).ShouldNot(ContainElement("start"))
+	})
+})
@@ -0,0 +1,62 @@
+package gcloud
+
+import (
+	"fmt"
+
+	bspinner "github.com/briandowns/spinner"
+
+	"github.com/bakito/gws/internal/log"
+	"github.com/bakito/gws/internal/spinner"
+)
+
+// reporter reports the progress of an operation on a workstation.
+type reporter interface {
+	// Waiting reports a long-running step.
+	Waiting(format string, args ...any)
+	// Logf reports a finished step or a message.
+	Logf(format string, args ...any)
+	// Stop stops the reporting of a running step.
+	Stop()
+}
+
+// consoleReporter shows long-running steps as spinner on the console.
+type consoleReporter struct {
+	spinny *bspinner.Spinner
+}
+
+func (r *consoleReporter) Waiting(format string, args ...any) {
+	r.Stop()
+	r.spinny = spinner.Start(" " + fmt.Sprintf(format, args...))
+}
+
+func (r *consoleReporter) Logf(format string, args ...any) {
+	r.Stop()
+	log.Logf(format, args...)
+}
+
+func (r *consoleReporter) Stop() {
+	if r.spinny != nil {
+		r.spinny.Stop()
+		r.spinny = nil
+	}
+}
+
+// ProgressFunc receives the progress of the workstation of a context.
+// Waiting is true while a long-running step is in progress.
+type ProgressFunc func(context, msg string, waiting bool)
+
+// progressReporter forwards the progress of a context to a ProgressFunc.
+type progressReporter struct {
+	context  string
+	progress ProgressFunc
+}
+
+func (r *progressReporter) Waiting(format string, args ...any) {
+	r.progress(r.context, fmt.Sprintf(format, args...), true)
+}
+
+func (r *progressReporter) Logf(format string, args ...any) {
+	r.progress(r.context, fmt.Sprintf(format, args...), false)
+}
+
+func (*progressReporter) Stop() {}
@@ -0,0 +1,74 @@
+package gcloud
+
+import (
+	"context"
+	"net"
+	"path"
+	"time"
+
+	"cloud.google.com/go/workstations/apiv1/workstationspb"
+
+	"github.com/bakito/gws/internal/types"
+)
+
+// WorkstationStatus describes the live state of the workstation of a context.
+type WorkstationStatus struct {
+	WorkstationInfo
+	Reconciling     bool
+	IdleTimeout     time.Duration
+	RunningTimeout  time.Duration
+	TunnelAddress   string
+	TunnelListening bool
+}
+
+// Uptime returns the duration since the last start if the workstation is running.
+func (s *WorkstationStatus) Uptime() time.Duration {
+	if s.State != workstationspb.Workstation_STATE_RUNNING || s.StartTime.IsZero() {
+		return 0
+	}
+	return time.Since(s.StartTime)
+}
+
+// GetStatus fetches the workstation and its config of the current context
+// and checks if a local tunnel is listening on the context port.
+func GetStatus(ctx context.Context, cfg *types.Config) (*WorkstationStatus, error) {
+	sshContext, c, ws, err := setup(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer closeIt(c)
+
+	configName := sshContext.GCloud.ConfigName()
+	wsConfig, err := c.GetWorkstationConfig(ctx, &workstationspb.GetWorkstationConfigRequest{Name: configName})
+	if err != nil {
+		return nil, err
+	}
+
+	status := &WorkstationStatus{
+		WorkstationInfo: WorkstationInfo{
+			Context: cfg.CurrentContextName,
+			Name:    path.Base(ws.GetName()),
+			Cluster: sshContext.GCloud.Cluster,
+			Config:  path.Base(configName),
+			State:   ws.GetState(),
+			Host:    ws.GetHost(),
+		},
+		Reconciling:    ws.GetReconciling(),
+		IdleTimeout:    wsConfig.GetIdleTimeout().AsDuration(),
+		RunningTimeout: wsConfig.GetRunningTimeout().AsDuration(),
+	}
+	network, address := sshContext.ListenAddress(0)
+	status.TunnelAddress = address
+	if network == "unix" {
+		status.TunnelAddress = types.ListenUnixPrefix + address
+	}
+	if ws.GetStartTime() != nil {
+		status.StartTime = ws.GetStartTime().AsTime()
+	}
+
+	if conn, err := (&net.Dialer{Timeout: time.Second}).DialContext(ctx, network, address); err == nil {
+		closeIt(conn)
+		status.TunnelListening = true
+	}
+	return status, nil
+}
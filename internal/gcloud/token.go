@@ -0,0 +1,160 @@
+package gcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bakito/gws/internal/log"
+	"github.com/bakito/gws/internal/types"
+)
+
+// RevokeURL the endpoint used to revoke tokens.
+var RevokeURL = "https://oauth2.googleapis.com/revoke"
+
+// ErrNoToken is returned when no token is stored.
+var ErrNoToken = errors.New("no token stored, please login")
+
+// TokenInfo describes the stored token of an account.
+type TokenInfo struct {
+	Account         string
+	Default         bool
+	Email           string
+	Scopes          []string
+	Expiry          time.Time
+	HasRefreshToken bool
+}
+
+// Expired returns true if the access token is expired.
+func (i *TokenInfo) Expired() bool {
+	return !i.Expiry.IsZero() && i.Expiry.Before(time.Now())
+}
+
+// ForceLogin runs a fresh login for the account, ignoring the stored token.
+func ForceLogin(ctx context.Context, cfg *types.Config, account string) error {
+	_, err := login(ctx, cfg, account)
+	return err
+}
+
+// GetTokenInfo returns the info of the stored tokens of all accounts.
+func GetTokenInfo(cfg *types.Config) ([]TokenInfo, error) {
+	if cfg.Tokens == nil || len(cfg.Tokens.Accounts) == 0 {
+		return nil, ErrNoToken
+	}
+
+	var infos []TokenInfo
+	for _, account := range cfg.Tokens.AccountNames() {
+		tk := cfg.Tokens.Accounts[account]
+		infos = append(infos, TokenInfo{
+			Account:         account,
+			Default:         account == cfg.Tokens.Default,
+			Email:           tk.Email(),
+			Scopes:          strings.Fields(tk.Scope),
+			Expiry:          tk.Token.Expiry,
+			HasRefreshToken: tk.Token.RefreshToken != "",
+		})
+	}
+	return infos, nil
+}
+
+// Logout deletes the stored token of the account, if account is empty the token of the default account is deleted.
+func Logout(cfg *types.Config, account string) error {
+	return logout(cfg, cfg.ResolveAccount(account))
+}
+
+// logout deletes the token stored with exactly the account as key.
+func logout(cfg *types.Config, account string) error {
+	if !hasToken(cfg, account) {
+		return fmt.Errorf("%w for account %q", ErrNoToken, account)
+	}
+	if err := cfg.DeleteToken(account); err != nil {
+		return err
+	}
+	log.Logf("🗑️ Deleted the stored token of %s", accountName(account))
+	return nil
+}
+
+// LogoutAll deletes the stored tokens of all accounts.
+func LogoutAll(cfg *types.Config) error {
+	if err := cfg.DeleteAllTokens(); err != nil {
+		return err
+	}
+	log.Log("🗑️ Deleted the stored tokens")
+	return nil
+}
+
+// Revoke revokes the stored token of the account at Google and deletes it.
+// If account is empty, the token of the default account is revoked.
+func Revoke(ctx context.Context, cfg *types.Config, account string) error {
+	return revoke(ctx, cfg, cfg.ResolveAccount(account))
+}
+
+// RevokeAll revokes the stored tokens of all accounts at Google and deletes them.
+func RevokeAll(ctx context.Context, cfg *types.Config) error {
+	if cfg.Tokens == nil || len(cfg.Tokens.Accounts) == 0 {
+		return ErrNoToken
+	}
+	for _, account := range cfg.Tokens.AccountNames() {
+		if err := revoke(ctx, cfg, account); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func revoke(ctx context.Context, cfg *types.Config, account string) error {
+	if !hasToken(cfg, account) {
+		return fmt.Errorf("%w for account %q", ErrNoToken, account)
+	}
+	tk := cfg.Tokens.Accounts[account]
+	// revoking the refresh token also revokes the access tokens issued with it
+	token := tk.Token.RefreshToken
+	if token == "" {
+		token = tk.Token.AccessToken
+	}
+	if token == "" {
+		return fmt.Errorf("%w for account %q", ErrNoToken, account)
+	}
+
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, RevokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer closeIt(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to revoke token (%s): %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	log.Logf("🚫 Revoked the token of %s", accountName(account))
+	return logout(cfg, account)
+}
+
+// hasToken returns true if a token is stored with exactly the account as key.
+func hasToken(cfg *types.Config, account string) bool {
+	if cfg.Tokens == nil {
+		return false
+	}
+	_, ok := cfg.Tokens.Accounts[account]
+	return ok
+}
+
+// accountName returns the account for messages, a legacy token without id token has no account.
+func accountName(account string) string {
+	if account == "" {
+		return "the unknown account"
+	}
+	return account
+}
@@ -0,0 +1,83 @@
+package gcloud
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/bakito/gws/internal/types"
+)
+
+// tokenCheckPeriod the interval of the periodic token check.
+const tokenCheckPeriod = 10 * time.Minute
+
+// tokenManager is a goroutine safe token source of an account.
+// Refreshed tokens are written to the token store, tokens refreshed by other gws processes are reused.
+type tokenManager struct {
+	cfg     *types.Config
+	account string
+	refresh func(token oauth2.Token) (*oauth2.Token, error)
+	stop    context.CancelFunc
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+func newTokenManager(ctx context.Context, cfg *types.Config, account string, token *oauth2.Token) *tokenManager {
+	conf := oauthConfig(cfg)
+	m := &tokenManager{
+		cfg:     cfg,
+		account: account,
+		token:   token,
+		refresh: func(token oauth2.Token) (*oauth2.Token, error) {
+			return conf.TokenSource(ctx, &token).Token()
+		},
+	}
+
+	if cfg.TokenCheck {
+		// Start periodic check
+		ctx, m.stop = context.WithCancel(ctx)
+		go m.periodicCheck(ctx)
+	}
+	return m
+}
+
+func (m *tokenManager) periodicCheck(ctx context.Context) {
+	ticker := time.NewTicker(tokenCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = m.Token()
+		}
+	}
+}
+
+// Token returns a valid token, concurrent calls share a single refresh.
+func (m *tokenManager) Token() (*oauth2.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.token.Valid() {
+		return m.token, nil
+	}
+
+	token, err := m.cfg.RefreshToken(m.account, m.refresh)
+	if err != nil {
+		return nil, err
+	}
+	m.token = token
+	return token, nil
+}
+
+// Stop stops the periodic check.
+func (m *tokenManager) Stop() {
+	if m.stop != nil {
+		m.stop()
+	}
+}
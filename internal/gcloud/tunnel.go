@@ -8,8 +8,11 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	workstations "cloud.google.com/go/workstations/apiv1"
@@ -21,239 +24,424 @@ import (
 	"github.com/bakito/gws/internal/types"
 )
 
+const (
+	// sshPort the port of the ssh daemon of the workstation.
+	sshPort = 22
+
+	dialAttempts       = 8
+	dialBackoffInitial = 500 * time.Millisecond
+	dialBackoffMax     = 30 * time.Second
+)
+
+// TunnelState the state of the tunnel.
+type TunnelState string
+
+const (
+	// TunnelListening the tunnel is listening and the workstation is reachable.
+	TunnelListening TunnelState = "listening"
+	// TunnelReconnecting the connection to the workstation failed and is retried.
+	TunnelReconnecting TunnelState = "reconnecting"
+	// TunnelRestarting the workstation stopped and is restarted.
+	TunnelRestarting TunnelState = "restarting workstation"
+)
+
+// TunnelOptions configures the tunnel.
+type TunnelOptions struct {
+	// Port the local ssh port, the port of the context is used if 0.
+	Port int
+	// Restart restarts the workstation if it stopped while the tunnel is open.
+	Restart bool
+	// OnState receives the state changes of the tunnel.
+	OnState func(state TunnelState)
+	// Metrics collects the traffic of the connections if not nil.
+	Metrics *TunnelMetrics
+	// Context the name of the context to tunnel, the current context is used if empty.
+	Context string
+	// Logger receives the logs of the tunnel, the global logger is used if nil.
+	Logger log.Logger
+}
+
 type tunnel struct {
-	headers http.Header
 	wsName  string
 	wsHost  string
 	client  *workstations.Client
+	restart bool
+	timeout time.Duration
+	// attempts the number of attempts to connect to the workstation
+	attempts int
+	onState  func(state TunnelState)
+	metrics  *TunnelMetrics
+	logger   log.Logger
+	// keepalive the interval of the websocket pings, 0 disables them
+	keepalive time.Duration
+	// idleTimeout closes connections without traffic, 0 disables it
+	idleTimeout time.Duration
+	// conns limits the concurrent connections if not nil
+	conns chan struct{}
+	// closeGrace overrides the default grace of half-closed connections if > 0
+	closeGrace time.Duration
+	// restartMu ensures only one connection checks and restarts the workstation at a time
+	restartMu sync.Mutex
+
+	// tokenMu guards the auth token used by concurrent connections
+	tokenMu     sync.RWMutex
+	token       string
+	tokenExpiry time.Time
 }
 
-func TCPTunnelWithPassphrase(ctx context.Context, cfg *types.Config, port int) error {
-	sshContext, c, ws, err := setup(ctx, cfg)
+func newTunnel(cfg *types.Config, c *workstations.Client, ws *workstationspb.Workstation) *tunnel {
+	t := &tunnel{
+		wsHost:      ws.GetHost(),
+		wsName:      ws.GetName(),
+		client:      c,
+		attempts:    dialAttempts,
+		keepalive:   cfg.KeepaliveInterval(),
+		idleTimeout: cfg.IdleTimeout(),
+	}
+	if maxConns := cfg.MaxConnections(); maxConns > 0 {
+		t.conns = make(chan struct{}, maxConns)
+	}
+	return t
+}
+
+func TCPTunnelWithPassphrase(ctx context.Context, cfg *types.Config, opts TunnelOptions) error {
+	sshContext := cfg.CurrentContext()
+	if opts.Context != "" {
+		var err error
+		if sshContext, err = cfg.Context(opts.Context); err != nil {
+			return err
+		}
+	}
+	sshContext, c, ws, err := setupContext(ctx, cfg, sshContext)
 	if err != nil {
 		return err
 	}
 	defer closeIt(c)
 
-	t := &tunnel{
-		headers: http.Header{},
-		wsHost:  ws.GetHost(),
-		wsName:  ws.GetName(),
-		client:  c,
+	t := newTunnel(cfg, c, ws)
+	t.restart = opts.Restart
+	t.timeout = cfg.WorkstationTimeout(sshContext)
+	t.onState = opts.OnState
+	t.metrics = opts.Metrics
+	t.logger = opts.Logger
+	if err := t.setAuthToken(ctx); err != nil {
+		return err
 	}
 	go t.refreshAuthToken(ctx)
-	t.setAuthToken(ctx)
 
-	p := sshContext.Port
-	if port != 0 {
-		p = port
+	network, address := sshContext.ListenAddress(opts.Port)
+	listener, err := t.listen(ctx, network, address, sshPort)
+	if err != nil {
+		t.logf("🚨 Failed to start %s listener: %v", network, err)
+		return err
 	}
+	defer closeIt(listener)
 
-	lc := net.ListenConfig{}
-	sshAddress := net.JoinHostPort("127.0.0.1", strconv.Itoa(p))
-	listener, err := lc.Listen(ctx, "tcp", sshAddress)
+	t.logf("🕳️ Opening tunnel to %s and listening on %s %s ...", sshContext.GCloud.Name, network, address)
+
+	for _, f := range sshContext.Forwards {
+		fl, err := t.forward(ctx, f)
+		if err != nil {
+			return err
+		}
+		defer closeIt(fl)
+	}
+
+	if sshContext.KnownHostsFile != "" {
+		go t.updateKnownHosts(sshContext, listener.Addr(), cfg.SSHTimeout())
+	}
+	t.setState(TunnelListening)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// ForwardPorts forwards the local ports to the ports of the workstation of the current context until ctx is done.
+// If no forwards are given, the forwards of the context are used.
+// If restart is true, the workstation is restarted if it stopped while forwarding.
+func ForwardPorts(ctx context.Context, cfg *types.Config, forwards []types.Forward, restart bool) error {
+	sshContext, c, ws, err := setup(ctx, cfg)
 	if err != nil {
-		log.Logf("🚨 Failed to start TCP listener: %v", err)
 		return err
 	}
-	defer closeIt(listener)
+	defer closeIt(c)
+
+	if len(forwards) == 0 {
+		forwards = sshContext.Forwards
+	}
+	if len(forwards) == 0 {
+		return fmt.Errorf("no forwards defined for context %q", cfg.CurrentContextName)
+	}
+
+	t := newTunnel(cfg, c, ws)
+	t.restart = restart
+	t.timeout = cfg.WorkstationTimeout(sshContext)
+	if err := t.setAuthToken(ctx); err != nil {
+		return err
+	}
+	go t.refreshAuthToken(ctx)
+
+	for _, f := range forwards {
+		fl, err := t.forward(ctx, f)
+		if err != nil {
+			return err
+		}
+		defer closeIt(fl)
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
 
-	log.Logf("🕳️ Opening tunnel to %s and listening on local ssh port %d ...", sshContext.GCloud.Name, p)
+// forward listens on the local port of the forward and serves the connections to the remote port.
+func (t *tunnel) forward(ctx context.Context, f types.Forward) (net.Listener, error) {
+	listener, err := t.listen(ctx, "tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(f.Local())), f.RemotePort)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for forward %s: %w", f, err)
+	}
+	t.logf("🔀 Forwarding local port %d to port %d of workstation %s", f.Local(), f.RemotePort, path.Base(t.wsName))
+	return listener, nil
+}
 
-	// Create an error channel to handle errors from goroutines
-	errChan := make(chan error, 1)
+// listen opens a local listener on the address and serves its connections to the remote port of the workstation.
+// A unix socket is only accessible by the user.
+func (t *tunnel) listen(ctx context.Context, network, address string, remotePort int) (net.Listener, error) {
+	if network == "unix" {
+		if err := removeStaleSocket(ctx, address); err != nil {
+			return nil, err
+		}
+	}
+	lc := net.ListenConfig{}
+	listener, err := lc.Listen(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		if err := os.Chmod(address, 0o600); err != nil {
+			closeIt(listener)
+			return nil, err
+		}
+	}
 
-	// Start accepting connections in a separate goroutine
 	go func() {
 		for {
-			select {
-			case <-ctx.Done():
-				errChan <- ctx.Err()
-				return
-			default:
-				clientConn, err := listener.Accept()
-				if err != nil {
-					if !errors.Is(err, net.ErrClosed) {
-						log.Logf("🚨 Failed to accept connection: %v", err)
-					}
-					continue
+			clientConn, err := listener.Accept()
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
 				}
-				log.Log("🤝 Accepted TCP connection")
-				go t.handleConnection(clientConn)
+				t.logf("🚨 Failed to accept connection: %v", err)
+				continue
+			}
+			if !t.acquireConn() {
+				t.logf("⛔ Rejected connection on %s, the limit of %d connections is reached", address, cap(t.conns))
+				closeIt(clientConn)
+				continue
 			}
+			t.logf("🤝 Accepted connection on %s", address)
+			go func() {
+				defer t.releaseConn()
+				t.handleConnection(ctx, clientConn, remotePort)
+			}()
 		}
 	}()
+	return listener, nil
+}
 
-	if sshContext.KnownHostsFile != "" {
-		go updateKnownHosts(sshContext, sshAddress, p, cfg.SSHTimeout())
+// removeStaleSocket removes the unix socket left over by a previous tunnel, a socket in use is not removed.
+func removeStaleSocket(ctx context.Context, socket string) error {
+	fi, err := os.Lstat(socket)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.MkdirAll(filepath.Dir(socket), 0o700)
 	}
-
-	// Wait for either context cancellation or error
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case err := <-errChan:
-		if errors.Is(err, context.Canceled) {
-			return nil
-		}
+	if err != nil {
 		return err
 	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", socket)
+	}
+	if conn, err := (&net.Dialer{Timeout: time.Second}).DialContext(ctx, "unix", socket); err == nil {
+		closeIt(conn)
+		return fmt.Errorf("socket %s is already in use", socket)
+	}
+	return os.Remove(socket)
 }
 
-func updateKnownHosts(
-	sshContext *types.Context,
-	address string,
-	port int,
-	timeout time.Duration,
-) {
-	if sshContext.KnownHostsFile == "" {
+// updateKnownHosts updates the known_hosts entries of the tunnel with the host keys of all types of the workstation.
+func (t *tunnel) updateKnownHosts(sshContext *types.Context, addr net.Addr, timeout time.Duration) {
+	hosts := knownHostsNames(sshContext, addr)
+	if len(hosts) == 0 {
 		return
 	}
 
-	// Get host key by connecting to the address
-	knownHost, err := ssh.GetHostKey(address, timeout)
+	// Get the host keys by connecting to the address
+	keys, err := ssh.GetHostKeys(addr.Network(), addr.String(), timeout)
 	if err != nil {
-		log.Logf("🚨 Error getting host key: %v", err)
+		t.logf("🚨 Error getting host keys: %v", err)
 		return
 	}
 
-	f, err := os.ReadFile(sshContext.KnownHostsFile)
+	changed, err := ssh.NewKnownHosts(sshContext.KnownHostsFile).Update(hosts, keys)
 	if err != nil {
-		log.Logf("🚨 Error reading known_hosts %s file: %v", sshContext.KnownHostsFile, err)
+		t.logf("🚨 Error updating known_hosts file %s: %v", sshContext.KnownHostsFile, err)
 		return
 	}
+	if changed {
+		t.logf("📝 KnownHosts file %s updated for %s", sshContext.KnownHostsFile, strings.Join(hosts, ", "))
+	}
+}
 
-	lines := strings.Split(string(f), "\n")
-	found := false
-	changed := false
-	linePrefix := fmt.Sprintf("[127.0.0.1]:%d", port)
-	for i, line := range lines {
-		if strings.HasPrefix(line, linePrefix) {
-			if line != knownHost {
-				lines[i] = knownHost
-				changed = true
-			}
-			found = true
-			break
+// RemoveKnownHosts removes the entries of the local tunnel from the known_hosts file of the context.
+func RemoveKnownHosts(sshContext *types.Context) error {
+	if sshContext.KnownHostsFile == "" {
+		return nil
+	}
+
+	var addr net.Addr
+	network, address := sshContext.ListenAddress(0)
+	if network == "tcp" {
+		var err error
+		if addr, err = net.ResolveTCPAddr(network, address); err != nil {
+			return err
 		}
 	}
-	if !found {
-		lines = append(lines, knownHost)
-		changed = true
+	hosts := knownHostsNames(sshContext, addr)
+	if len(hosts) == 0 {
+		return nil
 	}
 
+	changed, err := ssh.NewKnownHosts(sshContext.KnownHostsFile).Remove(hosts)
+	if err != nil {
+		return err
+	}
 	if changed {
-		err = os.WriteFile(sshContext.KnownHostsFile, []byte(strings.Join(lines, "\n")), 0o644)
-		if err != nil {
-			log.Logf("🚨 Error writing known_hosts file: %v", err)
-			return
-		}
-		log.Logf("📝 KnownHosts file %s updated for %s", sshContext.KnownHostsFile, linePrefix)
+		log.Logf("📝 KnownHosts file %s cleaned for %s", sshContext.KnownHostsFile, strings.Join(hosts, ", "))
 	}
+	return nil
 }
 
-func (t *tunnel) connectWebsocket() (*websocket.Conn, error) {
-	wsURL := fmt.Sprintf("wss://%s/_workstation/tcp/%d", t.wsHost, 22)
-	// Establish a persistent WebSocket connection
-	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, t.headers)
-	if err != nil {
-		if resp != nil {
-			body, _ := io.ReadAll(resp.Body)
-			defer closeIt(resp.Body)
-			log.Log(string(body))
+// knownHostsNames returns the host names of the known_hosts entries of the tunnel listening on addr.
+// A unix socket has no host name, only the alias of the context is used.
+func knownHostsNames(sshContext *types.Context, addr net.Addr) []string {
+	var hosts []string
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		ip := tcpAddr.IP
+		if ip.IsUnspecified() {
+			// ssh connects to the loopback address of a listener on all interfaces
+			ip = net.IPv4(127, 0, 0, 1)
 		}
-		log.Logf("🚨 Failed to connect to WebSocket %q: %v", wsURL, err)
-		return nil, err
+		hosts = append(hosts, net.JoinHostPort(ip.String(), strconv.Itoa(tcpAddr.Port)))
 	}
-	return conn, nil
-}
-
-// handleConnection forwards data between the TCP client and the WebSocket connection.
-func (t *tunnel) handleConnection(clientConn net.Conn) {
-	wsConn, err := t.connectWebsocket()
-	if err != nil {
-		return
+	if sshContext.KnownHostsAlias != "" {
+		hosts = append(hosts, sshContext.KnownHostsAlias)
 	}
+	return hosts
+}
 
-	// Create a local context to coordinate the shutdown of both goroutines
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	defer closeIt(clientConn)
-	defer closeIt(wsConn)
+// connectWebsocket connects to the port of the workstation. Failed dials are retried with exponential backoff,
+// the auth token is renewed on 401/403 responses and a stopped workstation is restarted if enabled.
+func (t *tunnel) connectWebsocket(ctx context.Context, port int) (*websocket.Conn, error) {
+	wsURL := fmt.Sprintf("wss://%s/_workstation/tcp/%d", t.wsHost, port)
+	backoff := dialBackoffInitial
+	for attempt := 1; ; attempt++ {
+		// Establish a persistent WebSocket connection
+		conn, resp, err := websocket.DefaultDialer.DialContext(ctx, wsURL, t.authHeader())
+		if err == nil {
+			if attempt > 1 {
+				t.logf("🔌 Reconnected to WebSocket %q", wsURL)
+				t.setState(TunnelListening)
+			}
+			return conn, nil
+		}
 
-	// Create a goroutine to send data from TCP client to WebSocket
-	go func() {
-		defer cancel() // Trigger cancel if the TCP client disconnects
-		buf := make([]byte, 32*1024)
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				n, err := clientConn.Read(buf)
-				if err != nil {
-					return // EOF or closed connection is handled by the defer cancel()
-				}
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			body, _ := io.ReadAll(resp.Body)
+			closeIt(resp.Body)
+			if len(body) > 0 {
+				t.log(string(body))
+			}
+		}
+		t.logf("🚨 Failed to connect to WebSocket %q: %v", wsURL, err)
+		if attempt >= t.attempts || ctx.Err() != nil {
+			return nil, err
+		}
 
-				// Send TCP data over WebSocket
-				if err := wsConn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
-					return
-				}
+		t.setState(TunnelReconnecting)
+		if status == http.StatusUnauthorized || status == http.StatusForbidden {
+			if err := t.setAuthToken(ctx); err != nil {
+				t.logf("🚨 Error generating token: %v", err)
 			}
+		} else if err := t.ensureRunning(ctx); err != nil {
+			return nil, err
 		}
-	}()
 
-	// Read data from WebSocket and send to the TCP client
-	for {
+		t.logf("🔁 Reconnecting in %s (attempt %d/%d) ...", backoff, attempt+1, t.attempts)
 		select {
 		case <-ctx.Done():
-			return
-		default:
-			_, msg, err := wsConn.ReadMessage()
-			if err != nil {
-				var ce *websocket.CloseError
-				if !errors.As(err, &ce) && !errors.Is(err, net.ErrClosed) {
-					log.Logf("🚨 Error reading from WebSocket: %v", err)
-				}
-				return
-			}
-
-			// Send WebSocket data to the TCP client
-			_, err = clientConn.Write(msg)
-			if err != nil {
-				// Prevent logging expected errors when the connection is closed or aborted by the host
-				if !errors.Is(err, net.ErrClosed) && !strings.Contains(err.Error(), "wsasend") {
-					log.Logf("🚨 Error writing to TCP connection: %v", err)
-				}
-				return
-			}
+			return nil, ctx.Err()
+		case <-time.After(backoff):
 		}
+		backoff = min(backoff*2, dialBackoffMax)
 	}
 }
 
-func (t *tunnel) refreshAuthToken(ctx context.Context) {
-	ticker := time.NewTicker(30 * time.Minute)
-	defer ticker.Stop()
+// ensureRunning checks if the workstation is still running, a stopped workstation is restarted if enabled.
+func (t *tunnel) ensureRunning(ctx context.Context) error {
+	t.restartMu.Lock()
+	defer t.restartMu.Unlock()
 
-	for {
-		select {
-		case <-ticker.C:
-			t.setAuthToken(ctx)
-		case <-ctx.Done():
-			return
+	ws, err := t.client.GetWorkstation(ctx, &workstationspb.GetWorkstationRequest{Name: t.wsName})
+	if err != nil {
+		// the workstation API might be unavailable as well, keep retrying
+		t.logf("🚨 Error getting workstation: %v", err)
+		return nil
+	}
+
+	name := path.Base(t.wsName)
+	switch ws.GetState() {
+	case workstationspb.Workstation_STATE_RUNNING:
+		return nil
+	case workstationspb.Workstation_STATE_STARTING:
+		// wait until started
+	default:
+		if !t.restart {
+			return fmt.Errorf("workstation %s is %s", name, stateName(ws.GetState()))
 		}
+		t.logf("🔄 Workstation %s is %s, restarting it", name, stateName(ws.GetState()))
+	}
+
+	t.setState(TunnelRestarting)
+	r := &progressReporter{progress: func(_, msg string, _ bool) { t.log(msg) }}
+	if _, err := startWorkstation(ctx, t.client, ws, name, t.timeout, r); err != nil {
+		return err
 	}
+	// the token of the stopped workstation is not valid anymore
+	if err := t.setAuthToken(ctx); err != nil {
+		return err
+	}
+	t.setState(TunnelReconnecting)
+	return nil
 }
 
-func (t *tunnel) setAuthToken(ctx context.Context) {
-	tr, err := t.client.GenerateAccessToken(ctx, &workstationspb.GenerateAccessTokenRequest{Workstation: t.wsName})
-	if err != nil {
-		log.Logf("🚨 Error generating token: %v", err)
+func (t *tunnel) setState(state TunnelState) {
+	if t.onState != nil {
+		t.onState(state)
+	}
+}
+
+// log logs the message with the logger of the tunnel.
+func (t *tunnel) log(msg string) {
+	if t.logger != nil {
+		t.logger(msg)
 		return
 	}
-	t.headers["Authorization"] = []string{"Bearer " + tr.GetAccessToken()}
-	log.Log("🎫 Got new Tunnel Auth Token")
+	log.Log(msg)
+}
+
+func (t *tunnel) logf(format string, args ...any) {
+	t.log(fmt.Sprintf(format, args...))
 }
 
 func closeIt(cl io.Closer) {
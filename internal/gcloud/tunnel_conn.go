@@ -0,0 +1,239 @@
+package gcloud
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// keepaliveMissed the number of keepalive intervals without any frame after which a connection is dead.
+	keepaliveMissed = 3
+	// closeGrace the default maximum duration without output of the workstation after the client input ended.
+	closeGrace = 10 * time.Second
+)
+
+// acquireConn reserves a connection slot, false is returned if the connection limit is reached.
+func (t *tunnel) acquireConn() bool {
+	if t.conns == nil {
+		return true
+	}
+	select {
+	case t.conns <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *tunnel) releaseConn() {
+	if t.conns != nil {
+		<-t.conns
+	}
+}
+
+// handleConnection forwards data between the TCP client and the WebSocket connection to the port of the workstation.
+func (t *tunnel) handleConnection(ctx context.Context, clientConn net.Conn, port int) {
+	defer closeIt(clientConn)
+
+	cm := t.metrics.add(clientConn.RemoteAddr().String(), port)
+	defer t.metrics.remove(cm)
+
+	wsConn, err := t.connectWebsocket(ctx, port)
+	if err != nil {
+		return
+	}
+	defer closeIt(wsConn)
+
+	t.bridge(clientConn, wsConn, cm)
+}
+
+// ping pings the workstation periodically until ctx is done.
+// The pongs are handled by the reader of the bridge.
+func (t *tunnel) ping(ctx context.Context, wsConn *websocket.Conn) {
+	ticker := time.NewTicker(t.keepalive)
+	defer ticker.Stop()
+	for {
+		// the payload is used to measure the round-trip latency
+		payload := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+		if err := wsConn.WriteControl(websocket.PingMessage, payload, time.Now().Add(t.keepalive)); err != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// bridge forwards data between the client and the WebSocket connection until both directions are closed.
+// The end of the client input only stops the forwarding to the workstation, as the workstation would stop
+// sending after a close frame. Its output is forwarded until it closes or sends no output for the close grace.
+// The end of the workstation output is propagated by closing the write side of the client.
+// The traffic is counted with cm if not nil.
+func (t *tunnel) bridge(client io.ReadWriter, wsConn *websocket.Conn, cm *connMetrics) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		// lastActivity the time of the last data transfer in any direction
+		lastActivity atomic.Int64
+		halfClosed   atomic.Bool
+		// deadlineMu guards the read deadline against the concurrent half-close
+		deadlineMu sync.Mutex
+	)
+	touch := func() { lastActivity.Store(time.Now().UnixNano()) }
+	touch()
+
+	// any frame of the workstation proves the connection alive, after the half-close only its output
+	// extends the grace, so a silent workstation is closed after the grace even if it answers the pings
+	extendDeadline := func(output bool) {
+		deadlineMu.Lock()
+		defer deadlineMu.Unlock()
+		switch {
+		case halfClosed.Load():
+			if output {
+				_ = wsConn.SetReadDeadline(time.Now().Add(t.halfCloseGrace()))
+			}
+		case t.keepalive > 0:
+			_ = wsConn.SetReadDeadline(time.Now().Add(keepaliveMissed * t.keepalive))
+		}
+	}
+	extendDeadline(false)
+	wsConn.SetPongHandler(func(data string) error {
+		if sent, err := strconv.ParseInt(data, 10, 64); err == nil {
+			t.metrics.setLatency(cm, time.Since(time.Unix(0, sent)))
+		}
+		extendDeadline(false)
+		return nil
+	})
+	if t.keepalive > 0 {
+		go t.ping(ctx, wsConn)
+	}
+
+	if t.idleTimeout > 0 {
+		go t.closeIdle(ctx, wsConn, &lastActivity)
+	}
+
+	clientDone := make(chan struct{})
+	go func() {
+		defer close(clientDone)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := client.Read(buf)
+			if n > 0 {
+				touch()
+				if werr := wsConn.WriteMessage(websocket.BinaryMessage, buf[:n]); werr != nil {
+					// the workstation is gone, drain the client so it is not reset while still sending
+					_, _ = io.Copy(io.Discard, client)
+					return
+				}
+				cm.addOut(n)
+			}
+			if errors.Is(err, io.EOF) {
+				// half-close: the output of the workstation is still forwarded until it closes as well
+				deadlineMu.Lock()
+				halfClosed.Store(true)
+				_ = wsConn.SetReadDeadline(time.Now().Add(t.halfCloseGrace()))
+				deadlineMu.Unlock()
+				return
+			}
+			if err != nil {
+				// Unblock the WebSocket reader if the client connection failed
+				closeIt(wsConn)
+				return
+			}
+		}
+	}()
+
+	// Read data from WebSocket and send to the client
+read:
+	for {
+		_, msg, err := wsConn.ReadMessage()
+		if err != nil {
+			var ce *websocket.CloseError
+			var ne net.Error
+			switch {
+			case errors.As(err, &ce), errors.Is(err, net.ErrClosed):
+			case errors.As(err, &ne) && ne.Timeout():
+				if halfClosed.Load() {
+					t.logf("💤 Closing connection, no output for %s after the input was closed", t.halfCloseGrace())
+				} else {
+					t.logf("💤 Closing connection, no keepalive response for %s", keepaliveMissed*t.keepalive)
+				}
+			default:
+				t.logf("🚨 Error reading from WebSocket: %v", err)
+			}
+			break read
+		}
+		extendDeadline(true)
+		touch()
+
+		// Send WebSocket data to the client
+		n, err := client.Write(msg)
+		cm.addIn(n)
+		if err != nil {
+			// Prevent logging expected errors when the connection is closed or aborted by the host
+			if !errors.Is(err, net.ErrClosed) && !strings.Contains(err.Error(), "wsasend") {
+				t.logf("🚨 Error writing to TCP connection: %v", err)
+			}
+			break read
+		}
+	}
+
+	// the workstation closed its output, let the client finish
+	closeWrite(client)
+	select {
+	case <-clientDone:
+	case <-time.After(t.halfCloseGrace()):
+	}
+
+	// both directions are done, a close frame already sent by the workstation was answered by the reader
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	_ = wsConn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// halfCloseGrace returns the maximum duration to wait for the other side after one side closed its direction.
+func (t *tunnel) halfCloseGrace() time.Duration {
+	if t.closeGrace > 0 {
+		return t.closeGrace
+	}
+	return closeGrace
+}
+
+// closeIdle closes the connection if no data was transferred for the idle timeout.
+func (t *tunnel) closeIdle(ctx context.Context, wsConn *websocket.Conn, lastActivity *atomic.Int64) {
+	ticker := time.NewTicker(min(t.idleTimeout/2, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(time.Unix(0, lastActivity.Load())) >= t.idleTimeout {
+				t.logf("💤 Closing connection idle for %s", t.idleTimeout)
+				closeIt(wsConn)
+				return
+			}
+		}
+	}
+}
+
+// closeWrite closes the write side of the client, or the client if it does not support half-close.
+func closeWrite(client io.ReadWriter) {
+	switch c := client.(type) {
+	case interface{ CloseWrite() error }:
+		_ = c.CloseWrite()
+	case io.Closer:
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,210 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Tunnel", func() {
+	Context("nextTokenRefresh", func() {
+		It("should refresh the token ahead of its expiry", func() {
+			t := &tunnel{tokenExpiry: time.Now().Add(time.Hour)}
+			Ω(t.nextTokenRefresh()).Should(BeNumerically("~", time.Hour-tokenRefreshMargin, time.Second))
+		})
+
+		It("should retry an expired token after the retry interval", func() {
+			t := &tunnel{tokenExpiry: time.Now().Add(-time.Minute)}
+			Ω(t.nextTokenRefresh()).Should(Equal(tokenRetryInterval))
+		})
+	})
+
+	Context("bridge", func() {
+		var server *httptest.Server
+		BeforeEach(func() {
+			upgrader := websocket.Upgrader{}
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+				defer closeIt(conn)
+				if r.URL.Path == "/silent" {
+					// answers the pings while reading, but never sends output
+					for {
+						if _, _, err := conn.ReadMessage(); err != nil {
+							return
+						}
+					}
+				}
+				if r.URL.Path == "/stream" {
+					// a long-running remote command, streaming its output after the input ended
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+					for i := range 12 {
+						time.Sleep(50 * time.Millisecond)
+						if err := conn.WriteMessage(websocket.BinaryMessage, []byte(fmt.Sprintf("line %d\n", i))); err != nil {
+							return
+						}
+					}
+					msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+					_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+					return
+				}
+				for {
+					mt, msg, err := conn.ReadMessage()
+					if err != nil {
+						return
+					}
+					// the response is delayed, like the answer of a remote command
+					time.Sleep(50 * time.Millisecond)
+					if err := conn.WriteMessage(mt, msg); err != nil {
+						return
+					}
+				}
+			}))
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should bridge stdio to the websocket until the input is closed", func() {
+			wsConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			in, inWriter := io.Pipe()
+			out := gbytes.NewBuffer()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				(&tunnel{closeGrace: 200 * time.Millisecond}).bridge(stdio{Reader: in, Writer: out}, wsConn, nil)
+			}()
+
+			_, err = inWriter.Write([]byte("SSH-2.0-test"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Eventually(out).Should(gbytes.Say("SSH-2.0-test"))
+
+			Ω(inWriter.Close()).ShouldNot(HaveOccurred())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("should forward the output after the input is closed", func() {
+			wsConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			out := gbytes.NewBuffer()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				t := &tunnel{closeGrace: 200 * time.Millisecond}
+				t.bridge(stdio{Reader: strings.NewReader("git-upload-pack"), Writer: out}, wsConn, nil)
+			}()
+
+			Eventually(out).Should(gbytes.Say("git-upload-pack"))
+			Eventually(done).Should(BeClosed())
+			Ω(out.Closed()).Should(BeTrue())
+		})
+
+		It("should forward output streamed for longer than the close grace after the input is closed", func() {
+			wsConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/stream", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			out := gbytes.NewBuffer()
+			done := make(chan struct{})
+			start := time.Now()
+			go func() {
+				defer close(done)
+				t := &tunnel{closeGrace: 500 * time.Millisecond}
+				t.bridge(stdio{Reader: strings.NewReader("tail"), Writer: out}, wsConn, nil)
+			}()
+
+			Eventually(done, 5*time.Second).Should(BeClosed())
+			Ω(time.Since(start)).Should(BeNumerically(">", 500*time.Millisecond))
+			Ω(string(out.Contents())).Should(HaveSuffix("line 11\n"))
+			Ω(out.Closed()).Should(BeTrue())
+		})
+
+		It("should close a silent connection after the close grace even if it answers the pings", func() {
+			wsConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/silent", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			out := gbytes.NewBuffer()
+			done := make(chan struct{})
+			start := time.Now()
+			go func() {
+				defer close(done)
+				t := &tunnel{keepalive: 20 * time.Millisecond, closeGrace: 300 * time.Millisecond}
+				t.bridge(stdio{Reader: strings.NewReader("scp"), Writer: out}, wsConn, nil)
+			}()
+
+			Eventually(done, 5*time.Second).Should(BeClosed())
+			Ω(time.Since(start)).Should(BeNumerically("~", 300*time.Millisecond, 250*time.Millisecond))
+			Ω(out.Closed()).Should(BeTrue())
+		})
+
+		It("should close an idle connection", func() {
+			wsConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			in, inWriter := io.Pipe()
+			out := gbytes.NewBuffer()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				(&tunnel{idleTimeout: 200 * time.Millisecond}).bridge(stdio{Reader: in, Writer: out}, wsConn, nil)
+			}()
+
+			// the write side of the client is closed, the client closes its side as well
+			Eventually(out.Closed).Should(BeTrue())
+			Ω(inWriter.Close()).ShouldNot(HaveOccurred())
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("acquireConn", func() {
+		It("should limit the concurrent connections", func() {
+			t := &tunnel{conns: make(chan struct{}, 1)}
+			Ω(t.acquireConn()).Should(BeTrue())
+			Ω(t.acquireConn()).Should(BeFalse())
+			t.releaseConn()
+			Ω(t.acquireConn()).Should(BeTrue())
+		})
+	})
+
+	Context("listen", func() {
+		It("should listen on a unix socket only accessible by the user", func() {
+			socket := filepath.Join(GinkgoT().TempDir(), "gws.sock")
+			// a socket left over by a previous tunnel
+			stale, err := net.Listen("unix", socket)
+			Ω(err).ShouldNot(HaveOccurred())
+			ul, ok := stale.(*net.UnixListener)
+			Ω(ok).Should(BeTrue())
+			ul.SetUnlinkOnClose(false)
+			Ω(stale.Close()).ShouldNot(HaveOccurred())
+
+			listener, err := (&tunnel{}).listen(context.Background(), "unix", socket, sshPort)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer closeIt(listener)
+
+			fi, err := os.Stat(socket)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(fi.Mode().Perm()).Should(Equal(os.FileMode(0o600)))
+
+			_, err = (&tunnel{}).listen(context.Background(), "unix", socket, sshPort)
+			Ω(err).Should(MatchError(ContainSubstring("already in use")))
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package gcloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/workstations/apiv1/workstationspb"
+)
+
+const (
+	// tokenRefreshMargin the duration before the expiry of the auth token when it is refreshed.
+	tokenRefreshMargin = 5 * time.Minute
+	// tokenRetryInterval the minimal interval between token refreshes, used to retry failed refreshes.
+	tokenRetryInterval = 10 * time.Second
+	// tokenDefaultLifetime the assumed lifetime of an auth token without an expire time.
+	tokenDefaultLifetime = 30 * time.Minute
+	tokenAttempts        = 3
+)
+
+// authHeader returns the headers with the current auth token of the workstation.
+func (t *tunnel) authHeader() http.Header {
+	t.tokenMu.RLock()
+	defer t.tokenMu.RUnlock()
+	return http.Header{"Authorization": []string{"Bearer " + t.token}}
+}
+
+// nextTokenRefresh returns the duration until the auth token has to be refreshed.
+func (t *tunnel) nextTokenRefresh() time.Duration {
+	t.tokenMu.RLock()
+	defer t.tokenMu.RUnlock()
+	return max(time.Until(t.tokenExpiry)-tokenRefreshMargin, tokenRetryInterval)
+}
+
+// refreshAuthToken refreshes the auth token ahead of its expiry until ctx is done.
+func (t *tunnel) refreshAuthToken(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(t.nextTokenRefresh()):
+			if err := t.setAuthToken(ctx); err != nil {
+				t.logf("🚨 Error generating token, retrying in %s: %v", tokenRetryInterval, err)
+			}
+		}
+	}
+}
+
+// setAuthToken generates a new auth token for the workstation, failed attempts are retried.
+func (t *tunnel) setAuthToken(ctx context.Context) error {
+	var err error
+	backoff := time.Second
+	for attempt := 1; attempt <= tokenAttempts; attempt++ {
+		var tr *workstationspb.GenerateAccessTokenResponse
+		tr, err = t.client.GenerateAccessToken(ctx, &workstationspb.GenerateAccessTokenRequest{Workstation: t.wsName})
+		if err == nil {
+			expiry := time.Now().Add(tokenDefaultLifetime)
+			if tr.GetExpireTime() != nil {
+				expiry = tr.GetExpireTime().AsTime()
+			}
+			t.tokenMu.Lock()
+			t.token = tr.GetAccessToken()
+			t.tokenExpiry = expiry
+			t.tokenMu.Unlock()
+			t.logf("🎫 Got new Tunnel Auth Token (expires: %s)", expiry.Local().Format(time.RFC822))
+			return nil
+		}
+		if attempt == tokenAttempts {
+			break
+		}
+
+		t.logf("🚨 Error generating token (attempt %d/%d): %v", attempt, tokenAttempts, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return fmt.Errorf("failed to generate the tunnel auth token: %w", err)
+}
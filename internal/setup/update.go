@@ -110,9 +110,10 @@ func (m Model) validateAndSubmit() (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 
-	if m.Config.Contexts != nil {
+	if current, ok := m.Config.Contexts[ctxName]; !ok || !current.ListensOnUnixSocket() {
 		for name, ctx := range m.Config.Contexts {
-			if ctx.Port == portVal && name != ctxName {
+			// a tunnel listening on a unix socket does not use its port
+			if ctx.Port == portVal && name != ctxName && !ctx.ListensOnUnixSocket() {
 				m.StatusMessage = fmt.Sprintf("Error: Port %d is already used by context %q.", portVal, name)
 				return m, nil
 			}
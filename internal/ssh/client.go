@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/bramvdbogaerde/go-scp"
@@ -123,6 +126,76 @@ func GetHostKey(addr string, timeout time.Duration) (string, error) {
 	return formatHostKey(tcpAddr, hostKey), nil
 }
 
+// hostKeyAlgorithms the host key algorithms used to fetch the keys of all types of a host.
+var hostKeyAlgorithms = []string{
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoECDSA384,
+	ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoRSASHA512,
+}
+
+// GetHostKeys fetches the host public keys of all supported types without authenticating.
+func GetHostKeys(network, addr string, timeout time.Duration) ([]ssh.PublicKey, error) {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		keys []ssh.PublicKey
+		errs []error
+	)
+	for _, algo := range hostKeyAlgorithms {
+		wg.Go(func() {
+			key, err := getHostKey(network, addr, timeout, algo)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", algo, err))
+				return
+			}
+			keys = append(keys, key)
+		})
+	}
+	wg.Wait()
+
+	if len(keys) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	// keep a stable order of the keys
+	slices.SortFunc(keys, func(a, b ssh.PublicKey) int { return strings.Compare(a.Type(), b.Type()) })
+	return keys, nil
+}
+
+// getHostKey fetches the host public key of the algorithm.
+func getHostKey(network, addr string, timeout time.Duration, algo string) (ssh.PublicKey, error) {
+	var hostKey ssh.PublicKey
+	config := &ssh.ClientConfig{
+		HostKeyCallback: func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return nil
+		},
+		HostKeyAlgorithms: []string{algo},
+		Timeout:           timeout,
+	}
+
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	// the handshake fails without auth methods, after the host key was verified
+	sshConn, _, _, err := ssh.NewClientConn(conn, addr, config)
+	if err == nil {
+		_ = sshConn.Close()
+	}
+	if hostKey == nil {
+		return nil, fmt.Errorf("failed to extract host key: %w", err)
+	}
+	return hostKey, nil
+}
+
 func clientWithTimeout(addr string, timeout time.Duration, clientConfig *ssh.ClientConfig) (*ssh.Client, error) {
 	// Use a dialer with TCP KeepAlive enabled to prevent connection drops
 	dialer := net.Dialer{
@@ -181,6 +254,9 @@ func (c *client) Execute(command string) (string, error) {
 	// Execute the command
 	output, err := session.CombinedOutput(command)
 	if err != nil {
+		if out := strings.TrimSpace(string(output)); out != "" {
+			return "", fmt.Errorf("failed to execute command: %w: %s", err, out)
+		}
 		return "", fmt.Errorf("failed to execute command: %w", err)
 	}
 	return string(output), nil
@@ -0,0 +1,255 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // used by the hashed known_hosts format of OpenSSH
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/bakito/gws/internal/env"
+)
+
+// KnownHosts manages the entries of hosts in a known_hosts file.
+// Other lines, comments and markers are kept as they are.
+type KnownHosts struct {
+	file string
+}
+
+// NewKnownHosts creates a manager of the known_hosts file.
+func NewKnownHosts(file string) *KnownHosts {
+	return &KnownHosts{file: env.ExpandEnv(file)}
+}
+
+// knownHostsLine a line of a known_hosts file.
+type knownHostsLine struct {
+	raw string
+	// hosts the host patterns of an entry, empty for comments, markers and invalid lines
+	hosts []string
+	key   ssh.PublicKey
+}
+
+// Update replaces the entries of the hosts with the keys, true is returned if the file changed.
+// The hosts are hashed if their existing entries are hashed, or else if the last entry of the file is hashed.
+func (k *KnownHosts) Update(hosts []string, keys []ssh.PublicKey) (bool, error) {
+	hosts = normalizeHosts(hosts)
+	lines, err := k.read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+
+	hashed := false
+	for _, l := range lines {
+		if len(l.hosts) > 0 {
+			hashed = isHashed(l.hosts[len(l.hosts)-1])
+		}
+	}
+	existing := make(map[string][]string)
+	for _, l := range lines {
+		for _, p := range l.hosts {
+			if h, ok := matchHost(p, hosts); ok {
+				existing[h] = append(existing[h], serializeKey(l.key))
+				hashed = isHashed(p)
+			}
+		}
+	}
+	if sameKeys(existing, hosts, keys) {
+		return false, nil
+	}
+
+	lines = removeHosts(lines, hosts)
+	for _, key := range keys {
+		if hashed {
+			// a hashed entry holds a single host
+			for _, h := range hosts {
+				lines = append(lines, knownHostsLine{raw: knownhosts.HashHostname(h) + " " + serializeKey(key)})
+			}
+		} else {
+			lines = append(lines, knownHostsLine{raw: knownhosts.Line(hosts, key)})
+		}
+	}
+	return true, k.write(lines)
+}
+
+// Remove removes the entries of the hosts, true is returned if the file changed.
+func (k *KnownHosts) Remove(hosts []string) (bool, error) {
+	hosts = normalizeHosts(hosts)
+	lines, err := k.read()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	kept := removeHosts(lines, hosts)
+	if slices.EqualFunc(kept, lines, func(a, b knownHostsLine) bool { return a.raw == b.raw }) {
+		return false, nil
+	}
+	return true, k.write(kept)
+}
+
+func (k *KnownHosts) read() ([]knownHostsLine, error) {
+	data, err := os.ReadFile(k.file)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []knownHostsLine
+	for raw := range strings.Lines(string(data)) {
+		l := knownHostsLine{raw: strings.TrimRight(raw, "\r\n")}
+		fields := strings.Fields(l.raw)
+		if len(fields) >= 3 && !strings.HasPrefix(fields[0], "#") && !strings.HasPrefix(fields[0], "@") {
+			if key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.Join(fields[1:], " "))); err == nil {
+				l.hosts = strings.Split(fields[0], ",")
+				l.key = key
+			}
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// write writes the lines atomically, keeping the mode of an existing file.
+func (k *KnownHosts) write(lines []knownHostsLine) error {
+	// replace the target of a symlink, not the link
+	file := k.file
+	if resolved, err := filepath.EvalSymlinks(file); err == nil {
+		file = resolved
+	}
+
+	mode := os.FileMode(0o600)
+	if fi, err := os.Stat(file); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString(l.raw)
+		buf.WriteString("\n")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+"-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// removeHosts removes the hosts from the entries, entries without other hosts are removed.
+func removeHosts(lines []knownHostsLine, hosts []string) []knownHostsLine {
+	var kept []knownHostsLine
+	for _, l := range lines {
+		if len(l.hosts) == 0 {
+			kept = append(kept, l)
+			continue
+		}
+		others := slices.DeleteFunc(slices.Clone(l.hosts), func(p string) bool {
+			_, ok := matchHost(p, hosts)
+			return ok
+		})
+		switch {
+		case len(others) == len(l.hosts):
+			kept = append(kept, l)
+		case len(others) > 0:
+			kept = append(kept, l.withHosts(others))
+		}
+	}
+	return kept
+}
+
+// withHosts returns the line with the host patterns replaced, the key and comment are kept as is.
+func (l knownHostsLine) withHosts(hosts []string) knownHostsLine {
+	line := strings.TrimLeft(l.raw, " \t")
+	rest := line[strings.IndexAny(line, " \t"):]
+	return knownHostsLine{raw: strings.Join(hosts, ",") + rest, hosts: hosts, key: l.key}
+}
+
+// matchHost returns the host matched by the pattern of an entry, only exact and hashed hosts are matched.
+func matchHost(pattern string, hosts []string) (string, bool) {
+	if !isHashed(pattern) {
+		i := slices.Index(hosts, knownhosts.Normalize(pattern))
+		if i < 0 {
+			return "", false
+		}
+		return hosts[i], true
+	}
+
+	parts := strings.Split(pattern, "|")
+	if len(parts) != 4 || parts[1] != "1" {
+		return "", false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", false
+	}
+	for _, h := range hosts {
+		mac := hmac.New(sha1.New, salt)
+		mac.Write([]byte(h))
+		if hmac.Equal(mac.Sum(nil), hash) {
+			return h, true
+		}
+	}
+	return "", false
+}
+
+func isHashed(pattern string) bool {
+	return strings.HasPrefix(pattern, "|")
+}
+
+// sameKeys returns true if each host has exactly the keys.
+func sameKeys(existing map[string][]string, hosts []string, keys []ssh.PublicKey) bool {
+	want := make([]string, 0, len(keys))
+	for _, k := range keys {
+		want = append(want, serializeKey(k))
+	}
+	slices.Sort(want)
+	for _, h := range hosts {
+		got := slices.Clone(existing[h])
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeHosts(hosts []string) []string {
+	normalized := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		normalized = append(normalized, knownhosts.Normalize(h))
+	}
+	return slices.Compact(normalized)
+}
+
+func serializeKey(key ssh.PublicKey) string {
+	return key.Type() + " " + base64.StdEncoding.EncodeToString(key.Marshal())
+}
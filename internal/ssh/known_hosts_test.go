@@ -0,0 +1,153 @@
+package ssh_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/bakito/gws/internal/ssh"
+)
+
+var _ = Describe("KnownHosts", func() {
+	var (
+		file    string
+		edKey   gossh.PublicKey
+		ecKey   gossh.PublicKey
+		oldKey  gossh.PublicKey
+		other   string
+		hosts   = []string{"127.0.0.1:2222", "my-workstation"}
+		newKeys func() []gossh.PublicKey
+	)
+	BeforeEach(func() {
+		file = filepath.Join(GinkgoT().TempDir(), "known_hosts")
+		edKey = newEd25519Key()
+		ecKey = newECDSAKey()
+		oldKey = newEd25519Key()
+		other = knownhosts.Line([]string{"github.com"}, newEd25519Key())
+		newKeys = func() []gossh.PublicKey { return []gossh.PublicKey{edKey, ecKey} }
+	})
+
+	// verify checks the keys of the hosts with the known_hosts callback of x/crypto.
+	verify := func(host string, key gossh.PublicKey) error {
+		cb, err := knownhosts.New(file)
+		Ω(err).ShouldNot(HaveOccurred())
+		return cb(host, hostAddr(host), key)
+	}
+
+	It("should update all key types and keep other entries", func() {
+		content := "# my hosts\n" + other + "\n" + knownhosts.Line([]string{"127.0.0.1:2222"}, oldKey) + "\n"
+		Ω(os.WriteFile(file, []byte(content), 0o640)).ShouldNot(HaveOccurred())
+
+		changed, err := ssh.NewKnownHosts(file).Update(hosts, newKeys())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(changed).Should(BeTrue())
+
+		for _, h := range []string{"127.0.0.1:2222", "my-workstation:22"} {
+			Ω(verify(h, edKey)).Should(Succeed())
+			Ω(verify(h, ecKey)).Should(Succeed())
+			Ω(verify(h, oldKey)).ShouldNot(Succeed())
+		}
+
+		data, err := os.ReadFile(file)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(data)).Should(HavePrefix("# my hosts\n" + other + "\n"))
+
+		fi, err := os.Stat(file)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(fi.Mode().Perm()).Should(Equal(os.FileMode(0o640)))
+
+		changed, err = ssh.NewKnownHosts(file).Update(hosts, newKeys())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(changed).Should(BeFalse())
+	})
+
+	It("should replace hashed entries with hashed entries", func() {
+		content := knownhosts.HashHostname("[127.0.0.1]:2222") + " " +
+			strings.TrimSpace(string(gossh.MarshalAuthorizedKey(oldKey)))
+		Ω(os.WriteFile(file, []byte(content+"\n"), 0o600)).ShouldNot(HaveOccurred())
+
+		changed, err := ssh.NewKnownHosts(file).Update(hosts[:1], newKeys())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(changed).Should(BeTrue())
+
+		data, err := os.ReadFile(file)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(data)).ShouldNot(ContainSubstring("127.0.0.1"))
+		Ω(strings.Count(string(data), "\n")).Should(Equal(2))
+		Ω(verify("127.0.0.1:2222", edKey)).Should(Succeed())
+		Ω(verify("127.0.0.1:2222", oldKey)).ShouldNot(Succeed())
+	})
+
+	It("should remove the hosts and keep the other hosts of an entry", func() {
+		content := knownhosts.Line([]string{"127.0.0.1:2222", "other-alias"}, edKey) + "\n" + other + "\n"
+		Ω(os.WriteFile(file, []byte(content), 0o600)).ShouldNot(HaveOccurred())
+
+		changed, err := ssh.NewKnownHosts(file).Remove(hosts)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(changed).Should(BeTrue())
+
+		Ω(verify("127.0.0.1:2222", edKey)).ShouldNot(Succeed())
+		Ω(verify("other-alias:22", edKey)).Should(Succeed())
+		Ω(verify("github.com:22", edKey)).ShouldNot(Succeed())
+
+		changed, err = ssh.NewKnownHosts(file).Remove(hosts)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(changed).Should(BeFalse())
+	})
+
+	It("should keep the comment of an entry with other hosts", func() {
+		content := knownhosts.Line([]string{"127.0.0.1:2222", "other-alias"}, edKey) + "  my comment\n"
+		Ω(os.WriteFile(file, []byte(content), 0o600)).ShouldNot(HaveOccurred())
+
+		changed, err := ssh.NewKnownHosts(file).Remove(hosts)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(changed).Should(BeTrue())
+
+		data, err := os.ReadFile(file)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(data)).Should(HavePrefix("other-alias "))
+		Ω(string(data)).Should(HaveSuffix("  my comment\n"))
+		Ω(verify("other-alias:22", edKey)).Should(Succeed())
+	})
+
+	It("should create a missing file", func() {
+		changed, err := ssh.NewKnownHosts(file).Update(hosts, newKeys())
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(changed).Should(BeTrue())
+		Ω(verify("my-workstation:22", ecKey)).Should(Succeed())
+	})
+})
+
+func newEd25519Key() gossh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	Ω(err).ShouldNot(HaveOccurred())
+	key, err := gossh.NewPublicKey(pub)
+	Ω(err).ShouldNot(HaveOccurred())
+	return key
+}
+
+func newECDSAKey() gossh.PublicKey {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Ω(err).ShouldNot(HaveOccurred())
+	key, err := gossh.NewPublicKey(&priv.PublicKey)
+	Ω(err).ShouldNot(HaveOccurred())
+	return key
+}
+
+// hostAddr a remote address equal to the host, so only the host is verified.
+type hostAddr string
+
+func (a hostAddr) Network() string { return "tcp" }
+func (a hostAddr) String() string  { return string(a) }
+
+var _ net.Addr = hostAddr("")
@@ -3,46 +3,107 @@ package tunnel
 import (
 	"context"
 
-	"github.com/charmbracelet/bubbles/textinput"
-
+	"github.com/bakito/gws/internal/gcloud"
 	"github.com/bakito/gws/internal/types"
 )
 
+// maxLogs the number of log lines kept per tab.
+const maxLogs = 100
+
 type Model struct {
 	Config   *types.Config
-	Port     int
+	Restart  bool
+	Tabs     []*Tab
+	Active   int
 	Styles   *Styles
 	Width    int
 	Height   int
-	Logs     []string
-	Err      error
 	Quitting bool
 
 	ctx    context.Context //nolint:containedctx
 	cancel context.CancelFunc
 
-	LogChan chan string
+	LogChan   chan logMsg
+	StateChan chan stateMsg
 }
 
-func NewModel(ctx context.Context, cfg *types.Config, port int) Model {
+// Tab the tunnel of a context.
+type Tab struct {
+	Name    string
+	Context *types.Context
+	Port    int
+	Address string
+	State   gcloud.TunnelState
+	Metrics *gcloud.TunnelMetrics
+	Stats   gcloud.TunnelStats
+	Logs    []string
+	Err     error
+	Running bool
+
+	// run the number of the current run, results of previous runs are ignored
+	run     int
+	cancel  context.CancelFunc
+	restart bool
+}
+
+// NewModel creates the model of the tunnels of the contexts.
+// The port overrides the port of the context if only one context is tunneled.
+func NewModel(ctx context.Context, cfg *types.Config, contexts []string, port int, restart bool) Model {
 	c, cancel := context.WithCancel(ctx)
-	ti := textinput.New()
-	ti.Placeholder = "Passphrase"
-	ti.EchoMode = textinput.EchoPassword
-	ti.EchoCharacter = '•'
-	ti.Focus()
-
-	return Model{
-		Config:  cfg,
-		Port:    port,
-		Styles:  DefaultStyles(),
-		ctx:     c,
-		cancel:  cancel,
-		LogChan: make(chan string, 10),
+
+	m := Model{
+		Config:    cfg,
+		Restart:   restart,
+		Styles:    DefaultStyles(),
+		ctx:       c,
+		cancel:    cancel,
+		LogChan:   make(chan logMsg, 10),
+		StateChan: make(chan stateMsg, 10),
+	}
+	for _, name := range contexts {
+		sshContext := cfg.Contexts[name]
+		p := 0
+		if len(contexts) == 1 {
+			p = port
+		}
+		network, address := sshContext.ListenAddress(p)
+		if network == "unix" {
+			address = types.ListenUnixPrefix + address
+		}
+		m.Tabs = append(m.Tabs, &Tab{
+			Name:    name,
+			Context: sshContext,
+			Port:    p,
+			Address: address,
+			Metrics: gcloud.NewTunnelMetrics(),
+		})
 	}
+	return m
 }
 
+func (t *Tab) addLog(l string) {
+	t.Logs = append(t.Logs, l)
+	if len(t.Logs) > maxLogs {
+		t.Logs = t.Logs[len(t.Logs)-maxLogs:]
+	}
+}
+
+// tabAll addresses the log to the active tab.
+const tabAll = -1
+
 type (
-	logMsg string
-	errMsg struct{ err error }
+	logMsg struct {
+		tab int
+		log string
+	}
+	stateMsg struct {
+		tab   int
+		state gcloud.TunnelState
+	}
+	doneMsg struct {
+		tab int
+		run int
+		err error
+	}
+	statsMsg []gcloud.TunnelStats
 )
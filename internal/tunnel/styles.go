@@ -19,6 +19,8 @@ type Styles struct {
 	ErrText lipgloss.Style
 	Info    lipgloss.Style
 	Success lipgloss.Style
+	Tab     lipgloss.Style
+	Active  lipgloss.Style
 }
 
 func DefaultStyles() *Styles {
@@ -40,5 +42,12 @@ func DefaultStyles() *Styles {
 		Foreground(LightGray)
 	s.Success = lipgloss.NewStyle().
 		Foreground(Green)
+	s.Tab = lipgloss.NewStyle().
+		Foreground(LightGray).
+		Padding(0, 1)
+	s.Active = s.Tab.
+		Bold(true).
+		Foreground(Indigo).
+		Underline(true)
 	return s
 }
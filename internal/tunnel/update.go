@@ -1,36 +1,96 @@
 package tunnel
 
 import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/bakito/gws/internal/gcloud"
 	"github.com/bakito/gws/internal/log"
 )
 
+// statsInterval the refresh interval of the connection table.
+const statsInterval = time.Second
+
 func (m Model) Init() tea.Cmd {
-	return tea.Batch(
-		func() tea.Msg {
-			return startTunnelMsg{}
-		},
-		m.waitForLog(),
-	)
+	// logs not written by a tunnel, e.g. of the login, are shown in the active tab
+	log.SetLogger(m.logger(tabAll))
+
+	cmds := []tea.Cmd{m.waitForLog(), m.waitForState(), m.tickStats()}
+	for i := range m.Tabs {
+		cmds = append(cmds, func() tea.Msg { return startTunnelMsg{tab: i} })
+	}
+	return tea.Batch(cmds...)
 }
 
-func (m Model) startTunnel() tea.Cmd {
-	log.SetLogger(func(log string) {
+func (m Model) tickStats() tea.Cmd {
+	return tea.Tick(statsInterval, func(time.Time) tea.Msg {
+		stats := make(statsMsg, len(m.Tabs))
+		for i, t := range m.Tabs {
+			stats[i] = t.Metrics.Snapshot()
+		}
+		return stats
+	})
+}
+
+func (m Model) logger(tab int) log.Logger {
+	return func(l string) {
 		select {
-		case m.LogChan <- log:
+		case m.LogChan <- logMsg{tab: tab, log: l}:
 		default:
 		}
-	})
+	}
+}
+
+// startTunnel starts the tunnel of the tab, the result is returned as doneMsg.
+func (m Model) startTunnel(tab int) tea.Cmd {
+	t := m.Tabs[tab]
+	if t.Running {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	t.run++
+	t.cancel = cancel
+	t.Running = true
+	t.Err = nil
+	t.State = ""
 
+	run := t.run
+	opts := gcloud.TunnelOptions{
+		Port:    t.Port,
+		Restart: m.Restart,
+		Metrics: t.Metrics,
+		Context: t.Name,
+		Logger:  m.logger(tab),
+		OnState: func(state gcloud.TunnelState) {
+			select {
+			case m.StateChan <- stateMsg{tab: tab, state: state}:
+			default:
+			}
+		},
+	}
 	return func() tea.Msg {
-		err := gcloud.TCPTunnelWithPassphrase(m.ctx, m.Config, m.Port)
-		if err != nil {
-			return errMsg{err}
+		defer cancel()
+		err := gcloud.TCPTunnelWithPassphrase(ctx, m.Config, opts)
+		return doneMsg{tab: tab, run: run, err: err}
+	}
+}
+
+// stopTunnel stops the tunnel of the tab, if restart is true it is started again once stopped.
+func (m Model) stopTunnel(tab int, restart bool) tea.Cmd {
+	t := m.Tabs[tab]
+	if !t.Running {
+		if restart {
+			return m.startTunnel(tab)
 		}
 		return nil
 	}
+	t.restart = restart
+	t.cancel()
+	return nil
 }
 
 func (m Model) waitForLog() tea.Cmd {
@@ -39,33 +99,88 @@ func (m Model) waitForLog() tea.Cmd {
 		if !ok {
 			return nil
 		}
-		return logMsg(l)
+		return l
+	}
+}
+
+func (m Model) waitForState() tea.Cmd {
+	return func() tea.Msg {
+		s, ok := <-m.StateChan
+		if !ok {
+			return nil
+		}
+		return s
 	}
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if msg.String() == "ctrl+c" {
-			m.Quitting = true
-			m.cancel()
-			return m, tea.Quit
-		}
+		return m.handleKey(msg)
 	case tea.WindowSizeMsg:
 		m.Width = msg.Width
 		m.Height = msg.Height
 	case startTunnelMsg:
-		return m, m.startTunnel()
+		return m, m.startTunnel(msg.tab)
 	case logMsg:
-		m.Logs = append(m.Logs, string(msg))
+		tab := msg.tab
+		if tab == tabAll {
+			tab = m.Active
+		}
+		m.Tabs[tab].addLog(msg.log)
 		return m, m.waitForLog()
-	case errMsg:
-		m.Err = msg.err
-		return m, nil
+	case statsMsg:
+		for i, s := range msg {
+			m.Tabs[i].Stats = s
+		}
+		return m, m.tickStats()
+	case stateMsg:
+		m.Tabs[msg.tab].State = msg.state
+		return m, m.waitForState()
+	case doneMsg:
+		t := m.Tabs[msg.tab]
+		if msg.run != t.run {
+			return m, nil
+		}
+		t.Running = false
+		t.State = ""
+		if msg.err != nil && !errors.Is(msg.err, context.Canceled) {
+			t.Err = msg.err
+		}
+		if t.restart {
+			t.restart = false
+			return m, m.startTunnel(msg.tab)
+		}
+	}
+	return m, nil
+}
+
+func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key := msg.String(); key {
+	case "ctrl+c", "q":
+		m.Quitting = true
+		m.cancel()
+		return m, tea.Quit
+	case "tab", "right", "l":
+		m.Active = (m.Active + 1) % len(m.Tabs)
+	case "shift+tab", "left", "h":
+		m.Active = (m.Active + len(m.Tabs) - 1) % len(m.Tabs)
+	case "s":
+		return m, m.startTunnel(m.Active)
+	case "r":
+		return m, m.stopTunnel(m.Active, true)
+	case "x":
+		return m, m.stopTunnel(m.Active, false)
+	default:
+		if i, err := strconv.Atoi(key); err == nil && i >= 1 && i <= len(m.Tabs) {
+			m.Active = i - 1
+		}
 	}
 	return m, nil
 }
 
 type (
-	startTunnelMsg struct{}
+	startTunnelMsg struct {
+		tab int
+	}
 )
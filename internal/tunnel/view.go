@@ -3,7 +3,9 @@ package tunnel
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/bakito/gws/internal/gcloud"
 	"github.com/bakito/gws/version"
 )
 
@@ -16,41 +18,141 @@ func (m Model) View() string {
 
 	b.WriteString(m.Styles.Title.Render(">_ GWS Tunnel"))
 	b.WriteString("\n\n")
-
-	currCtx := m.Config.CurrentContext()
-	port := m.Port
-	if port == 0 {
-		port = currCtx.Port
+	if len(m.Tabs) > 1 {
+		m.writeTabs(&b)
 	}
+
+	t := m.Tabs[m.Active]
 	b.WriteString(fmt.Sprintf("  Version:     %s\n", version.Version))
-	b.WriteString(fmt.Sprintf("  Context:     %s\n", m.Styles.Success.Render(m.Config.CurrentContextName)))
-	b.WriteString(fmt.Sprintf("  Workstation: %s\n", m.Styles.Success.Render(currCtx.GCloud.Name)))
-	b.WriteString(fmt.Sprintf("  Local Port:  %d\n", port))
+	b.WriteString(fmt.Sprintf("  Context:     %s\n", m.Styles.Success.Render(t.Name)))
+	workstation := m.Styles.ErrText.Render("no gcloud config")
+	if t.Context.GCloud != nil {
+		workstation = m.Styles.Success.Render(t.Context.GCloud.Name)
+	}
+	b.WriteString(fmt.Sprintf("  Workstation: %s\n", workstation))
+	b.WriteString(fmt.Sprintf("  Listen:      %s\n", t.Address))
+	b.WriteString(fmt.Sprintf("  Status:      %s\n", m.renderState(t)))
+	for i, f := range t.Context.Forwards {
+		label := ""
+		if i == 0 {
+			label = "Forwards:"
+		}
+		b.WriteString(fmt.Sprintf("  %-12s %d → %d\n", label, f.Local(), f.RemotePort))
+	}
 	b.WriteString("\n")
 
-	if m.Err != nil {
-		b.WriteString(m.Styles.ErrText.Render(fmt.Sprintf("Error: %v", m.Err)))
+	if t.Err != nil {
+		b.WriteString(m.Styles.ErrText.Render(fmt.Sprintf("Error: %v", t.Err)))
 		b.WriteString("\n\n")
 	}
 
+	m.writeConnections(&b, t.Stats)
+
 	b.WriteString(m.Styles.Info.Render("Logs:"))
 	b.WriteString("\n")
 
 	var logView string
-	if len(m.Logs) > 0 {
+	if len(t.Logs) > 0 {
 		start := 0
-		if len(m.Logs) > 10 {
-			start = len(m.Logs) - 10
+		if len(t.Logs) > 10 {
+			start = len(t.Logs) - 10
 		}
-		logView = strings.Join(m.Logs[start:], "\n")
+		logView = strings.Join(t.Logs[start:], "\n")
 	} else {
 		logView = "Waiting for connections..."
 	}
 	b.WriteString(logView)
 	b.WriteString("\n\n")
 
-	help := m.Styles.Help.Render("ctrl+c: quit")
-	b.WriteString(help)
+	help := "s: start • r: restart • x: stop • q/ctrl+c: quit"
+	if len(m.Tabs) > 1 {
+		help = "tab/shift+tab/1-9: switch context • " + help
+	}
+	b.WriteString(m.Styles.Help.Render(help))
 
 	return m.Styles.Border.Width(m.Width - 4).Render(b.String())
 }
+
+// writeTabs writes the tab bar with the name, port and state of each context.
+func (m Model) writeTabs(b *strings.Builder) {
+	tabs := make([]string, len(m.Tabs))
+	for i, t := range m.Tabs {
+		style := m.Styles.Tab
+		if i == m.Active {
+			style = m.Styles.Active
+		}
+		tabs[i] = style.Render(fmt.Sprintf("%d %s %s", i+1, t.Name, t.Address)) + " " + m.renderState(t)
+	}
+	b.WriteString("  " + strings.Join(tabs, m.Styles.Help.Render(" │ ")))
+	b.WriteString("\n\n")
+}
+
+// renderState renders the state of the tunnel of the tab.
+func (m Model) renderState(t *Tab) string {
+	switch {
+	case t.Err != nil:
+		return m.Styles.ErrText.Render("failed")
+	case !t.Running:
+		return m.Styles.ErrText.Render("stopped")
+	case t.State == "":
+		return m.Styles.Info.Render("starting")
+	case t.State == gcloud.TunnelListening:
+		return m.Styles.Success.Render(string(t.State))
+	default:
+		return m.Styles.ErrText.Render(string(t.State))
+	}
+}
+
+func (m Model) writeConnections(b *strings.Builder, stats gcloud.TunnelStats) {
+	b.WriteString(m.Styles.Info.Render("Connections:"))
+	b.WriteString("\n")
+	header := fmt.Sprintf("  %-22s %5s %9s %10s %10s %12s %12s %8s",
+		"Client", "Port", "Duration", "In", "Out", "In/s", "Out/s", "Latency")
+	b.WriteString(m.Styles.Help.Render(header))
+	b.WriteString("\n")
+	for _, c := range stats.Connections {
+		b.WriteString(fmt.Sprintf("  %-22s %5d %9s %10s %10s %12s %12s %8s\n",
+			c.ClientAddr,
+			c.RemotePort,
+			time.Since(c.Start).Truncate(time.Second),
+			FormatBytes(float64(c.BytesIn)),
+			FormatBytes(float64(c.BytesOut)),
+			FormatBytes(c.InRate)+"/s",
+			FormatBytes(c.OutRate)+"/s",
+			formatLatency(c.Latency),
+		))
+	}
+	total := fmt.Sprintf("  %-22s %5s %9s %10s %10s %12s %12s %8s",
+		fmt.Sprintf("Total (%d/%d)", len(stats.Connections), stats.Total),
+		"",
+		"",
+		FormatBytes(float64(stats.BytesIn)),
+		FormatBytes(float64(stats.BytesOut)),
+		FormatBytes(stats.InRate)+"/s",
+		FormatBytes(stats.OutRate)+"/s",
+		formatLatency(stats.Latency),
+	)
+	b.WriteString(m.Styles.Success.Render(total))
+	b.WriteString("\n\n")
+}
+
+// FormatBytes formats the bytes with binary units.
+func FormatBytes(b float64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%.0f B", b)
+	}
+	div, exp := float64(unit), 0
+	for n := b / unit; n >= unit && exp < 4; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", b/div, "KMGTP"[exp])
+}
+
+func formatLatency(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(100 * time.Microsecond).String()
+}
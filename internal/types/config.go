@@ -2,10 +2,13 @@ package types
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -19,15 +22,31 @@ const (
 	ConfigDir      = ".config/gws"
 )
 
+var (
+	// ErrConfigNotFound is returned if no config file is found.
+	ErrConfigNotFound = fmt.Errorf("%w: config file not found", os.ErrNotExist)
+	// ErrContextNotDefined is returned if a context is not defined in the config.
+	ErrContextNotDefined = errors.New("context not defined")
+)
+
 type Config struct {
 	Contexts           map[string]*Context  `yaml:"contexts"`
 	CurrentContextName string               `yaml:"currentContext"`
 	FilePath           string               `yaml:"-"`
 	TokenCheck         bool                 `yaml:"-"`
+	NoBrowser          bool                 `yaml:"-"`
 	FilePatches        map[string]FilePatch `yaml:"filePatches,omitempty"`
 	SSHTimeoutSeconds  int                  `yaml:"sshTimeoutSeconds,omitempty"`
+	Groups             map[string][]string  `yaml:"groups,omitempty"`
+	TokenStoreType     TokenStoreType       `yaml:"tokenStore,omitempty"`
+	OAuth              *OAuthClient         `yaml:"oauth,omitempty"`
+	Tunnel             *TunnelSettings      `yaml:"tunnel,omitempty"`
 	currentContext     *Context
-	Token              *TokenStorage `yaml:"-"`
+	Tokens             *Tokens `yaml:"-"`
+	tokenStore         TokenStore
+	tokenMu            sync.Mutex
+	// Timeout overrides the timeout for workstation state changes of all contexts.
+	Timeout time.Duration `yaml:"-"`
 }
 
 func (c *Config) CurrentContext() *Context {
@@ -49,6 +68,14 @@ func (c *Config) Load(fileName string) error {
 
 	c.FilePath = file
 
+	for _, name := range c.ContextNames() {
+		for _, f := range c.Contexts[name].Forwards {
+			if err := f.Validate(); err != nil {
+				return fmt.Errorf("context %q: %w", name, err)
+			}
+		}
+	}
+
 	if c.CurrentContextName == "" {
 		if len(c.Contexts) == 1 {
 			for k := range maps.Keys(c.Contexts) {
@@ -57,15 +84,129 @@ func (c *Config) Load(fileName string) error {
 		}
 	}
 
-	tk, err := LoadToken()
+	return c.SwitchContext(c.CurrentContextName, false)
+}
+
+// LoadToken loads the tokens from the configured token store.
+// The tokens are not loaded with the config, as the token store might require a passphrase.
+func (c *Config) LoadToken() error {
+	return c.withTokenLock(func(TokenStore) error { return nil })
+}
+
+// withTokenLock reloads the stored tokens while holding the process and file lock of the token store
+// and runs fn, so concurrent goroutines and gws processes never override each other's tokens.
+func (c *Config) withTokenLock(fn func(store TokenStore) error) error {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	store, err := c.TokenStore()
+	if err != nil {
+		return err
+	}
+	// ask for the passphrase before locking, a prompt must not block other gws processes
+	if u, ok := store.(unlocker); ok {
+		if err := u.Unlock(); err != nil {
+			return err
+		}
+	}
+	unlock, err := lockTokenStore()
 	if err != nil {
 		return err
 	}
-	c.Token = &TokenStorage{}
+	defer unlock()
+
+	tk, err := store.Load()
+	if err != nil {
+		return err
+	}
+	c.Tokens = &Tokens{}
 	if tk != nil {
-		c.Token.Token = *tk
+		c.Tokens = tk
 	}
-	return c.SwitchContext(c.CurrentContextName, false)
+	return fn(store)
+}
+
+// DeleteToken deletes the token of the account from the configured token store.
+func (c *Config) DeleteToken(account string) error {
+	return c.withTokenLock(func(store TokenStore) error {
+		c.Tokens.Remove(account)
+		if len(c.Tokens.Accounts) == 0 {
+			return store.Delete()
+		}
+		return store.Save(*c.Tokens)
+	})
+}
+
+// DeleteAllTokens deletes the tokens of all accounts from the configured token store.
+func (c *Config) DeleteAllTokens() error {
+	return c.withTokenLock(func(store TokenStore) error {
+		c.Tokens = &Tokens{}
+		return store.Delete()
+	})
+}
+
+// AccountToken returns the token of the account, or an empty token if the account has no token.
+// If account is empty, the token of the default account is returned.
+func (c *Config) AccountToken(account string) TokenStorage {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if s := c.Tokens.Get(account); s != nil {
+		return *s
+	}
+	return TokenStorage{}
+}
+
+// ResolveAccount returns the account, or the default account if account is empty.
+func (c *Config) ResolveAccount(account string) string {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if account == "" && c.Tokens != nil {
+		return c.Tokens.Default
+	}
+	return account
+}
+
+// RefreshToken returns a valid token of the account. The stored token is only refreshed with refresh
+// if it is not valid anymore, a token refreshed by another gws process in the meantime is reused.
+func (c *Config) RefreshToken(
+	account string,
+	refresh func(token oauth2.Token) (*oauth2.Token, error),
+) (*oauth2.Token, error) {
+	var token *oauth2.Token
+	err := c.withTokenLock(func(store TokenStore) error {
+		stored := c.Tokens.Get(account)
+		if stored == nil {
+			return fmt.Errorf("no token stored for account %q", account)
+		}
+		if stored.Token.Valid() {
+			tk := stored.Token
+			token = &tk
+			return nil
+		}
+
+		var err error
+		token, err = refresh(stored.Token)
+		if err != nil {
+			return err
+		}
+		_, err = c.setToken(store, account, *token)
+		return err
+	})
+	return token, err
+}
+
+// TokenStore returns the configured token store.
+func (c *Config) TokenStore() (TokenStore, error) {
+	if c.tokenStore == nil {
+		store, err := NewTokenStore(c.TokenStoreType)
+		if err != nil {
+			return nil, err
+		}
+		c.tokenStore = store
+	}
+	return c.tokenStore, nil
 }
 
 func (c *Config) SSHTimeout() time.Duration {
@@ -75,6 +216,17 @@ func (c *Config) SSHTimeout() time.Duration {
 	return time.Duration(c.SSHTimeoutSeconds) * time.Second
 }
 
+// WorkstationTimeout returns the timeout for state changes of the workstation of the given context.
+func (c *Config) WorkstationTimeout(sshContext *Context) time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	if sshContext != nil && sshContext.TimeoutSeconds > 0 {
+		return time.Duration(sshContext.TimeoutSeconds) * time.Second
+	}
+	return 10 * time.Minute
+}
+
 func ReadGWSFile(fileName string) (absoluteFile string, data []byte, err error) {
 	var file string
 	if fileName != "" {
@@ -92,7 +244,7 @@ func ReadGWSFile(fileName string) (absoluteFile string, data []byte, err error)
 			// Fallback to the legacy location for backward compatibility
 			legacyPath := filepath.Join(userHomeDir, ".gws.yaml")
 			if _, err := os.Stat(legacyPath); err != nil {
-				return "", nil, fmt.Errorf("%w: config file not found", os.ErrNotExist)
+				return "", nil, ErrConfigNotFound
 			}
 			file = legacyPath
 			log.Logf("⚠️  Using legacy config location. Consider moving to: %s", newConfigPath)
@@ -116,8 +268,9 @@ func DefaultConfigDir() (newConfigPath, userHomeDir string) {
 }
 
 func (c *Config) SwitchContext(newContext string, force bool) error {
-	if _, ok := c.Contexts[newContext]; !ok {
-		return fmt.Errorf("context with name %q not defined", newContext)
+	sshContext, err := c.Context(newContext)
+	if err != nil {
+		return err
 	}
 
 	if force || c.CurrentContextName != newContext {
@@ -127,11 +280,53 @@ func (c *Config) SwitchContext(newContext string, force bool) error {
 			return err
 		}
 	}
-	c.currentContext = c.Contexts[newContext]
+	c.currentContext = sshContext
 
 	return nil
 }
 
+// Context returns the context with the name.
+func (c *Config) Context(name string) (*Context, error) {
+	sshContext, ok := c.Contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrContextNotDefined, name)
+	}
+	return sshContext, nil
+}
+
+// ContextNames returns the sorted names of all contexts.
+func (c *Config) ContextNames() []string {
+	return slices.Sorted(maps.Keys(c.Contexts))
+}
+
+// GroupContexts returns the context names of the group.
+func (c *Config) GroupContexts(group string) ([]string, error) {
+	names, ok := c.Groups[group]
+	if !ok {
+		return nil, fmt.Errorf("group with name %q not defined", group)
+	}
+	for _, name := range names {
+		if _, ok := c.Contexts[name]; !ok {
+			return nil, fmt.Errorf("context with name %q of group %q not defined", name, group)
+		}
+	}
+	return names, nil
+}
+
+// RemoveContext removes the context from the config and saves it.
+func (c *Config) RemoveContext(name string) error {
+	if _, ok := c.Contexts[name]; !ok {
+		return fmt.Errorf("%w: %q", ErrContextNotDefined, name)
+	}
+	delete(c.Contexts, name)
+
+	if c.CurrentContextName == name {
+		c.CurrentContextName = ""
+		c.currentContext = nil
+	}
+	return c.save()
+}
+
 func (c *Config) save() error {
 	var buf bytes.Buffer
 	encoder := yaml.NewEncoder(&buf)
@@ -144,15 +339,59 @@ func (c *Config) save() error {
 	return os.WriteFile(c.FilePath, buf.Bytes(), 0o600)
 }
 
-func (c *Config) SetToken(token oauth2.Token) error {
-	if c.Token == nil {
-		c.Token = &TokenStorage{Token: token}
+// SetToken stores the token for the account and returns the account the token belongs to.
+// If account is empty, the token is stored for the account of its id token.
+func (c *Config) SetToken(account string, token oauth2.Token) (string, error) {
+	err := c.withTokenLock(func(store TokenStore) error {
+		var err error
+		account, err = c.setToken(store, account, token)
+		return err
+	})
+	return account, err
+}
+
+func (c *Config) setToken(store TokenStore, account string, token oauth2.Token) (string, error) {
+	var existing TokenStorage
+	stored := c.Tokens.Get(account)
+	if stored != nil {
+		existing = *stored
+	}
+	storage := NewTokenStorage(token)
+	// refreshed tokens might not contain the id token and scope
+	if storage.IDToken == "" {
+		storage.IDToken = existing.IDToken
+	}
+	if storage.Scope == "" {
+		storage.Scope = existing.Scope
+	}
+	// refreshed tokens might not contain the refresh token
+	if storage.Token.RefreshToken == "" {
+		storage.Token.RefreshToken = existing.Token.RefreshToken
+	}
+
+	email := storage.Email()
+	if account != "" && email != "" && email != account {
+		return "", fmt.Errorf("logged in as %q, but account %q is required", email, account)
+	}
+	if account == "" {
+		account = email
+		if account == "" {
+			account = c.Tokens.Default
+		}
 	}
 
-	if c.Token.Token.AccessToken != token.AccessToken {
+	// a legacy token without id token is stored without account, until its account is known
+	renamed := false
+	if legacy, ok := c.Tokens.Accounts[""]; ok && email != "" && stored == legacy {
+		c.Tokens.Rename("", email)
+		renamed = true
+	}
+
+	if existing.Token.AccessToken != token.AccessToken || c.Tokens.Get(account) == nil {
 		log.Logf("🎟️ Got new Google Access Token (expires: %s)", token.Expiry.Format(time.RFC822))
-		c.Token.Token = token
-		return SaveToken(c.Token.Token)
+	} else if !renamed {
+		return account, nil
 	}
-	return nil
+	c.Tokens.Set(account, &storage)
+	return account, store.Save(*c.Tokens)
 }
@@ -0,0 +1,156 @@
+package types_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+
+	"github.com/bakito/gws/internal/types"
+)
+
+var _ = Describe("Config", func() {
+	Context("Load", func() {
+		var homeDir string
+		BeforeEach(func() {
+			homeDir = GinkgoT().TempDir()
+			GinkgoT().Setenv("HOME", homeDir)
+			GinkgoT().Setenv("USERPROFILE", homeDir)
+		})
+
+		It("should fail with ErrConfigNotFound without config file", func() {
+			err := (&types.Config{}).Load("")
+			Ω(err).Should(MatchError(types.ErrConfigNotFound))
+		})
+
+		It("should fail with ErrContextNotDefined without current context", func() {
+			file := filepath.Join(homeDir, "config.yaml")
+			Ω(os.WriteFile(file, []byte(`contexts:
+  a: {}
+  b: {}
+`), 0o600)).ShouldNot(HaveOccurred())
+
+			cfg := &types.Config{}
+			Ω(cfg.Load(file)).Should(MatchError(types.ErrContextNotDefined))
+			Ω(cfg.ContextNames()).Should(Equal([]string{"a", "b"}))
+		})
+
+		It("should fail for an invalid forward port", func() {
+			file := filepath.Join(homeDir, "config.yaml")
+			Ω(os.WriteFile(file, []byte(`contexts:
+  a:
+    forwards:
+    - remotePort: 70000
+`), 0o600)).ShouldNot(HaveOccurred())
+
+			Ω((&types.Config{}).Load(file)).Should(MatchError(ContainSubstring("invalid remote port 70000")))
+		})
+	})
+
+	Context("RefreshToken", func() {
+		const account = "me@example.com"
+		var (
+			cfg       *types.Config
+			refreshed atomic.Int32
+			refresh   func(token oauth2.Token) (*oauth2.Token, error)
+		)
+		BeforeEach(func() {
+			homeDir := GinkgoT().TempDir()
+			GinkgoT().Setenv("HOME", homeDir)
+			GinkgoT().Setenv("USERPROFILE", homeDir)
+
+			tokens := types.Tokens{}
+			tokens.Set(account, &types.TokenStorage{Token: oauth2.Token{
+				AccessToken:  "expired",
+				RefreshToken: "refresh",
+				Expiry:       time.Now().Add(-time.Hour),
+			}})
+			Ω(types.SaveTokens(tokens)).ShouldNot(HaveOccurred())
+
+			cfg = &types.Config{}
+			Ω(cfg.LoadToken()).ShouldNot(HaveOccurred())
+
+			refreshed.Store(0)
+			refresh = func(token oauth2.Token) (*oauth2.Token, error) {
+				refreshed.Add(1)
+				return &oauth2.Token{
+					AccessToken:  "fresh",
+					RefreshToken: token.RefreshToken,
+					Expiry:       time.Now().Add(time.Hour),
+				}, nil
+			}
+		})
+
+		It("should refresh the token once for concurrent calls", func() {
+			var wg sync.WaitGroup
+			for range 5 {
+				wg.Go(func() {
+					token, err := cfg.RefreshToken(account, refresh)
+					Ω(err).ShouldNot(HaveOccurred())
+					Ω(token.AccessToken).Should(Equal("fresh"))
+				})
+			}
+			wg.Wait()
+			Ω(refreshed.Load()).Should(Equal(int32(1)))
+
+			stored, err := types.LoadTokens()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stored.Get(account).Token.AccessToken).Should(Equal("fresh"))
+		})
+
+		It("should reuse a token refreshed by another process", func() {
+			other := &types.Config{}
+			_, err := other.SetToken(account, oauth2.Token{AccessToken: "other", Expiry: time.Now().Add(time.Hour)})
+			Ω(err).ShouldNot(HaveOccurred())
+
+			token, err := cfg.RefreshToken(account, refresh)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(token.AccessToken).Should(Equal("other"))
+			Ω(token.RefreshToken).Should(Equal("refresh"))
+			Ω(refreshed.Load()).Should(BeZero())
+		})
+	})
+
+	Context("legacy token", func() {
+		BeforeEach(func() {
+			homeDir := GinkgoT().TempDir()
+			GinkgoT().Setenv("HOME", homeDir)
+			GinkgoT().Setenv("USERPROFILE", homeDir)
+
+			// the token.yaml of a version before multiple accounts, without id token
+			tokenDir := filepath.Join(homeDir, types.ConfigDir)
+			Ω(os.MkdirAll(tokenDir, 0o700)).ShouldNot(HaveOccurred())
+			Ω(os.WriteFile(filepath.Join(tokenDir, types.TokenFileName), []byte(`token:
+  accesstoken: expired
+  refreshtoken: refresh
+  expiry: 2020-01-01T00:00:00Z
+`), 0o600)).ShouldNot(HaveOccurred())
+		})
+
+		It("should move the token to the account once its email is known", func() {
+			cfg := &types.Config{}
+			Ω(cfg.LoadToken()).ShouldNot(HaveOccurred())
+			Ω(cfg.Tokens.AccountNames()).Should(Equal([]string{""}))
+
+			claims := base64.RawURLEncoding.EncodeToString([]byte(`{"email":"me@example.com"}`))
+			token, err := cfg.RefreshToken("", func(token oauth2.Token) (*oauth2.Token, error) {
+				fresh := &oauth2.Token{AccessToken: "fresh", Expiry: time.Now().Add(time.Hour)}
+				return fresh.WithExtra(map[string]any{"id_token": "header." + claims + ".signature"}), nil
+			})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(token.AccessToken).Should(Equal("fresh"))
+
+			stored, err := types.LoadTokens()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stored.AccountNames()).Should(Equal([]string{"me@example.com"}))
+			Ω(stored.Default).Should(Equal("me@example.com"))
+			Ω(stored.Get("me@example.com").Token.RefreshToken).Should(Equal("refresh"))
+		})
+	})
+})
@@ -0,0 +1,55 @@
+package types
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	// OAuthClientIDEnv the env variable overriding the OAuth client ID.
+	OAuthClientIDEnv = "GWS_OAUTH_CLIENT_ID"
+	// OAuthClientSecretEnv the env variable overriding the OAuth client secret.
+	OAuthClientSecretEnv = "GWS_OAUTH_CLIENT_SECRET"
+	// OAuthScopesEnv the env variable overriding the OAuth scopes (comma or space separated).
+	OAuthScopesEnv = "GWS_OAUTH_SCOPES"
+	// OAuthAuthURLEnv the env variable overriding the OAuth authorization endpoint.
+	OAuthAuthURLEnv = "GWS_OAUTH_AUTH_URL"
+	// OAuthTokenURLEnv the env variable overriding the OAuth token endpoint.
+	OAuthTokenURLEnv = "GWS_OAUTH_TOKEN_URL"
+)
+
+// OAuthClient defines the OAuth client used for the gws login.
+// Empty fields fall back to the client embedded at build time.
+type OAuthClient struct {
+	ClientID     string   `yaml:"clientID,omitempty"`
+	ClientSecret string   `yaml:"clientSecret,omitempty"`
+	Scopes       []string `yaml:"scopes,omitempty"`
+	AuthURL      string   `yaml:"authURL,omitempty"`
+	TokenURL     string   `yaml:"tokenURL,omitempty"`
+}
+
+// OAuthClient returns the configured OAuth client with the env variable overrides applied.
+func (c *Config) OAuthClient() OAuthClient {
+	var client OAuthClient
+	if c.OAuth != nil {
+		client = *c.OAuth
+		client.Scopes = append([]string(nil), c.OAuth.Scopes...)
+	}
+
+	if v := os.Getenv(OAuthClientIDEnv); v != "" {
+		client.ClientID = v
+	}
+	if v := os.Getenv(OAuthClientSecretEnv); v != "" {
+		client.ClientSecret = v
+	}
+	if v := os.Getenv(OAuthScopesEnv); v != "" {
+		client.Scopes = strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+	if v := os.Getenv(OAuthAuthURLEnv); v != "" {
+		client.AuthURL = v
+	}
+	if v := os.Getenv(OAuthTokenURLEnv); v != "" {
+		client.TokenURL = v
+	}
+	return client
+}
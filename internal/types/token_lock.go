@@ -0,0 +1,36 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/gofrs/flock"
+)
+
+const (
+	// TokenLockFileName the name of the advisory lock file guarding the token store across processes.
+	TokenLockFileName = "token.lock"
+
+	tokenLockTimeout = 30 * time.Second
+	tokenLockRetry   = 50 * time.Millisecond
+)
+
+// lockTokenStore acquires the advisory file lock of the token store and returns the function to release it.
+func lockTokenStore() (func(), error) {
+	tokenPath, err := GetTokenFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), tokenLockTimeout)
+	defer cancel()
+
+	fl := flock.New(filepath.Join(filepath.Dir(tokenPath), TokenLockFileName))
+	locked, err := fl.TryLockContext(ctx, tokenLockRetry)
+	if err != nil || !locked {
+		return nil, fmt.Errorf("failed to lock the token store: %w", err)
+	}
+	return func() { _ = fl.Unlock() }, nil
+}
@@ -1,22 +1,122 @@
 package types
 
 import (
-	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"maps"
 	"os"
 	"path/filepath"
-	"time"
+	"slices"
+	"strings"
 
 	"golang.org/x/oauth2"
-	"gopkg.in/yaml.v3"
-
-	"github.com/bakito/gws/internal/log"
 )
 
 const TokenFileName = "token.yaml"
 
+// TokenStorage holds the token of an account.
 type TokenStorage struct {
-	Token oauth2.Token `yaml:"token"`
+	Token   oauth2.Token `yaml:"token"`
+	IDToken string       `yaml:"idToken,omitempty"`
+	Scope   string       `yaml:"scope,omitempty"`
+}
+
+// NewTokenStorage creates a new token storage with the id token and scope of the token response.
+func NewTokenStorage(token oauth2.Token) TokenStorage {
+	storage := TokenStorage{Token: token}
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		storage.IDToken = idToken
+	}
+	if scope, ok := token.Extra("scope").(string); ok {
+		storage.Scope = scope
+	}
+	return storage
+}
+
+// Email returns the email claim of the (unverified) id token, or an empty string if it is not available.
+func (s *TokenStorage) Email() string {
+	parts := strings.Split(s.IDToken, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Email
+}
+
+// Tokens holds the tokens of all accounts keyed by the account email.
+type Tokens struct {
+	// Default the account used by contexts without an account.
+	Default  string                   `yaml:"default,omitempty"`
+	Accounts map[string]*TokenStorage `yaml:"accounts,omitempty"`
+}
+
+// Get returns the token of the account. If account is empty, the token of the default account is returned.
+func (t *Tokens) Get(account string) *TokenStorage {
+	if t == nil {
+		return nil
+	}
+	if account == "" {
+		account = t.Default
+	}
+	if s, ok := t.Accounts[account]; ok {
+		return s
+	}
+	if account == "" && len(t.Accounts) == 1 {
+		for _, s := range t.Accounts {
+			return s
+		}
+	}
+	return nil
+}
+
+// Set sets the token of the account, the first account becomes the default.
+func (t *Tokens) Set(account string, storage *TokenStorage) {
+	if t.Accounts == nil {
+		t.Accounts = make(map[string]*TokenStorage)
+	}
+	t.Accounts[account] = storage
+	if _, ok := t.Accounts[t.Default]; !ok {
+		t.Default = account
+	}
+}
+
+// Remove removes the token of the account.
+func (t *Tokens) Remove(account string) {
+	delete(t.Accounts, account)
+	if t.Default == account {
+		t.Default = ""
+		if len(t.Accounts) > 0 {
+			t.Default = t.AccountNames()[0]
+		}
+	}
+}
+
+// Rename moves the token of the account from to the account to, the default account follows the token.
+func (t *Tokens) Rename(from, to string) {
+	s, ok := t.Accounts[from]
+	if !ok {
+		return
+	}
+	delete(t.Accounts, from)
+	t.Accounts[to] = s
+	if t.Default == from {
+		t.Default = to
+	}
+}
+
+// AccountNames returns the sorted names of the accounts.
+func (t *Tokens) AccountNames() []string {
+	return slices.Sorted(maps.Keys(t.Accounts))
 }
 
 func GetTokenFilePath() (string, error) {
@@ -33,7 +133,7 @@ func GetTokenFilePath() (string, error) {
 	return filepath.Join(tokenDir, TokenFileName), nil
 }
 
-func LoadToken() (*oauth2.Token, error) {
+func LoadTokens() (*Tokens, error) {
 	tokenPath, err := GetTokenFilePath()
 	if err != nil {
 		return nil, err
@@ -47,46 +147,33 @@ func LoadToken() (*oauth2.Token, error) {
 		return nil, err
 	}
 
-	var storage TokenStorage
-	err = yaml.Unmarshal(data, &storage)
-	if err != nil {
-		return nil, err
-	}
-
-	return &storage.Token, nil
+	return unmarshalTokens(data)
 }
 
-func SaveToken(token oauth2.Token) error {
+func SaveTokens(tokens Tokens) error {
 	tokenPath, err := GetTokenFilePath()
 	if err != nil {
 		return err
 	}
 
-	storage := TokenStorage{Token: token}
-
-	var buf bytes.Buffer
-	encoder := yaml.NewEncoder(&buf)
-	encoder.SetIndent(2)
-
-	err = encoder.Encode(storage)
+	data, err := marshalTokens(tokens)
 	if err != nil {
 		return err
 	}
 
-	log.Logf("🎟️ Got new Google Access Token (expires: %s)", token.Expiry.Format(time.RFC822))
-	return os.WriteFile(tokenPath, buf.Bytes(), 0o600)
+	return os.WriteFile(tokenPath, data, 0o600)
 }
 
-func SetToken(token oauth2.Token) error {
-	// Check if token has changed before saving
-	existingToken, err := LoadToken()
+// DeleteTokens deletes the stored tokens.
+func DeleteTokens() error {
+	tokenPath, err := GetTokenFilePath()
 	if err != nil {
 		return err
 	}
 
-	if existingToken != nil && existingToken.AccessToken == token.AccessToken {
-		return nil // No change
+	err = os.Remove(tokenPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
 	}
-
-	return SaveToken(token)
+	return err
 }
@@ -0,0 +1,304 @@
+package types
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bakito/gws/internal/log"
+	"github.com/bakito/gws/internal/passwd"
+)
+
+const (
+	// EncryptedTokenFileName the name of the passphrase encrypted token file.
+	EncryptedTokenFileName = "token.enc"
+	// TokenPassphraseEnv the env variable providing the passphrase of the encrypted token file.
+	TokenPassphraseEnv = "GWS_TOKEN_PASSPHRASE"
+
+	keyringService = "gws"
+	keyringUser    = "oauth-token"
+	saltSize       = 16
+)
+
+// TokenStoreType the backend used to store the OAuth token.
+type TokenStoreType string
+
+const (
+	// TokenStoreFile stores the token in the plain token.yaml file (default).
+	TokenStoreFile TokenStoreType = "file"
+	// TokenStoreKeyring stores the token in the OS keyring (e.g. the Secret Service via D-Bus).
+	TokenStoreKeyring TokenStoreType = "keyring"
+	// TokenStoreEncryptedFile stores the token in a passphrase encrypted file.
+	TokenStoreEncryptedFile TokenStoreType = "encryptedFile"
+)
+
+// TokenStore persists the OAuth tokens.
+type TokenStore interface {
+	// Load returns the stored tokens or nil if no token is stored.
+	Load() (*Tokens, error)
+	Save(tokens Tokens) error
+	Delete() error
+}
+
+// unlocker is implemented by token stores requiring user input to access the tokens.
+// Unlock is called before the token store is locked, so other gws processes are not blocked by a prompt.
+type unlocker interface {
+	Unlock() error
+}
+
+// NewTokenStore returns the token store of the given type.
+func NewTokenStore(storeType TokenStoreType) (TokenStore, error) {
+	switch storeType {
+	case "", TokenStoreFile:
+		return fileTokenStore{}, nil
+	case TokenStoreKeyring:
+		return &migratingTokenStore{TokenStore: keyringTokenStore{}}, nil
+	case TokenStoreEncryptedFile:
+		return &migratingTokenStore{TokenStore: &encryptedFileTokenStore{}}, nil
+	default:
+		return nil, fmt.Errorf("unknown token store %q", storeType)
+	}
+}
+
+// fileTokenStore stores the token in the plain token.yaml file.
+type fileTokenStore struct{}
+
+func (fileTokenStore) Load() (*Tokens, error) {
+	return LoadTokens()
+}
+
+func (fileTokenStore) Save(tokens Tokens) error {
+	return SaveTokens(tokens)
+}
+
+func (fileTokenStore) Delete() error {
+	return DeleteTokens()
+}
+
+// migratingTokenStore migrates an existing plain token file into the wrapped store on first use.
+type migratingTokenStore struct {
+	TokenStore
+}
+
+func (s *migratingTokenStore) Load() (*Tokens, error) {
+	tokens, err := s.TokenStore.Load()
+	if err != nil || tokens != nil {
+		return tokens, err
+	}
+
+	plain, err := LoadTokens()
+	if err != nil || plain == nil {
+		return nil, err
+	}
+
+	if err := s.Save(*plain); err != nil {
+		return nil, fmt.Errorf("failed to migrate the plain token: %w", err)
+	}
+	if err := DeleteTokens(); err != nil {
+		return nil, err
+	}
+	log.Log("🔒 Migrated the plain token file into the token store")
+	return plain, nil
+}
+
+func (s *migratingTokenStore) Unlock() error {
+	if u, ok := s.TokenStore.(unlocker); ok {
+		return u.Unlock()
+	}
+	return nil
+}
+
+// keyringTokenStore stores the token in the OS keyring.
+type keyringTokenStore struct{}
+
+func (keyringTokenStore) Load() (*Tokens, error) {
+	data, err := keyring.Get(keyringService, keyringUser)
+	if err != nil {
+		if errors.Is(err, keyring.ErrNotFound) {
+			return nil, nil // No token yet
+		}
+		return nil, fmt.Errorf("failed to read the token from the keyring: %w", err)
+	}
+	return unmarshalTokens([]byte(data))
+}
+
+func (keyringTokenStore) Save(tokens Tokens) error {
+	data, err := marshalTokens(tokens)
+	if err != nil {
+		return err
+	}
+	if err := keyring.Set(keyringService, keyringUser, string(data)); err != nil {
+		return fmt.Errorf("failed to write the token to the keyring: %w", err)
+	}
+	return nil
+}
+
+func (keyringTokenStore) Delete() error {
+	err := keyring.Delete(keyringService, keyringUser)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// encryptedFileTokenStore stores the token in a file encrypted with AES-GCM and a key derived from a passphrase.
+type encryptedFileTokenStore struct {
+	passphrase []byte
+}
+
+func encryptedTokenFilePath() (string, error) {
+	tokenPath, err := GetTokenFilePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(tokenPath), EncryptedTokenFileName), nil
+}
+
+func (s *encryptedFileTokenStore) Load() (*Tokens, error) {
+	path, err := encryptedTokenFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil // No token yet
+		}
+		return nil, err
+	}
+	if len(data) < saltSize {
+		return nil, errors.New("invalid encrypted token file")
+	}
+
+	gcm, err := s.cipher(data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted token file")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		// forget the passphrase to allow a retry
+		s.passphrase = nil
+		return nil, errors.New("failed to decrypt the token file, wrong passphrase?")
+	}
+	return unmarshalTokens(plain)
+}
+
+func (s *encryptedFileTokenStore) Save(tokens Tokens) error {
+	path, err := encryptedTokenFilePath()
+	if err != nil {
+		return err
+	}
+
+	plain, err := marshalTokens(tokens)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	gcm, err := s.cipher(salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data := append(salt, nonce...)
+	data = gcm.Seal(data, nonce, plain, nil)
+	return os.WriteFile(path, data, 0o600)
+}
+
+func (*encryptedFileTokenStore) Delete() error {
+	path, err := encryptedTokenFilePath()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Unlock asks for the passphrase, if it is not provided by the env variable.
+func (s *encryptedFileTokenStore) Unlock() error {
+	if len(s.passphrase) > 0 || os.Getenv(TokenPassphraseEnv) != "" {
+		return nil
+	}
+	pass, err := passwd.Prompt("🔐 Please enter the passphrase of the token store:")
+	if err != nil {
+		return err
+	}
+	if pass == "" {
+		return errors.New("the passphrase of the token store must not be empty")
+	}
+	s.passphrase = []byte(pass)
+	return nil
+}
+
+func (s *encryptedFileTokenStore) cipher(salt []byte) (cipher.AEAD, error) {
+	if len(s.passphrase) == 0 {
+		// the passphrase is never prompted here, as the token store is locked
+		pass := os.Getenv(TokenPassphraseEnv)
+		if pass == "" {
+			return nil, errors.New("the passphrase of the token store is required")
+		}
+		s.passphrase = []byte(pass)
+	}
+
+	key, err := scrypt.Key(s.passphrase, salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func marshalTokens(tokens Tokens) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(tokens); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalTokens reads the tokens, a token in the legacy single account format is keyed by its account.
+func unmarshalTokens(data []byte) (*Tokens, error) {
+	var stored struct {
+		Tokens `yaml:",inline"`
+		Legacy TokenStorage `yaml:",inline"`
+	}
+	if err := yaml.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	tokens := stored.Tokens
+	if len(tokens.Accounts) == 0 && stored.Legacy.Token.RefreshToken != "" {
+		tokens.Set(stored.Legacy.Email(), &stored.Legacy)
+	}
+	return &tokens, nil
+}
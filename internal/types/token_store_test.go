@@ -0,0 +1,126 @@
+package types_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/oauth2"
+
+	"github.com/bakito/gws/internal/types"
+)
+
+var _ = Describe("TokenStore", func() {
+	var (
+		tokenDir string
+		token    types.Tokens
+	)
+	BeforeEach(func() {
+		homeDir := GinkgoT().TempDir()
+		GinkgoT().Setenv("HOME", homeDir)
+		GinkgoT().Setenv("USERPROFILE", homeDir)
+		GinkgoT().Setenv(types.TokenPassphraseEnv, "secret")
+		tokenDir = filepath.Join(homeDir, types.ConfigDir)
+
+		token = types.Tokens{}
+		token.Set("me@example.com", &types.TokenStorage{Token: oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}})
+	})
+
+	Context("encryptedFile", func() {
+		It("should save and load an encrypted token", func() {
+			store, err := types.NewTokenStore(types.TokenStoreEncryptedFile)
+			Ω(err).ShouldNot(HaveOccurred())
+
+			Ω(store.Save(token)).ShouldNot(HaveOccurred())
+
+			data, err := os.ReadFile(filepath.Join(tokenDir, types.EncryptedTokenFileName))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(data)).ShouldNot(ContainSubstring("refresh"))
+
+			loaded, err := store.Load()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(loaded.Get("me@example.com").Token.RefreshToken).Should(Equal("refresh"))
+		})
+
+		It("should fail with a wrong passphrase", func() {
+			store, err := types.NewTokenStore(types.TokenStoreEncryptedFile)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(store.Save(token)).ShouldNot(HaveOccurred())
+
+			GinkgoT().Setenv(types.TokenPassphraseEnv, "wrong")
+			store, err = types.NewTokenStore(types.TokenStoreEncryptedFile)
+			Ω(err).ShouldNot(HaveOccurred())
+			_, err = store.Load()
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("should not prompt for the passphrase while loading", func() {
+			store, err := types.NewTokenStore(types.TokenStoreEncryptedFile)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(store.Save(token)).ShouldNot(HaveOccurred())
+
+			// the passphrase is prompted by Unlock only, before the token store is locked
+			GinkgoT().Setenv(types.TokenPassphraseEnv, "")
+			store, err = types.NewTokenStore(types.TokenStoreEncryptedFile)
+			Ω(err).ShouldNot(HaveOccurred())
+			_, err = store.Load()
+			Ω(err).Should(MatchError(ContainSubstring("passphrase")))
+		})
+
+		It("should migrate the plain token file", func() {
+			Ω(types.SaveTokens(token)).ShouldNot(HaveOccurred())
+
+			store, err := types.NewTokenStore(types.TokenStoreEncryptedFile)
+			Ω(err).ShouldNot(HaveOccurred())
+			loaded, err := store.Load()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(loaded.Get("me@example.com").Token.RefreshToken).Should(Equal("refresh"))
+
+			Ω(filepath.Join(tokenDir, types.TokenFileName)).ShouldNot(BeAnExistingFile())
+			Ω(filepath.Join(tokenDir, types.EncryptedTokenFileName)).Should(BeAnExistingFile())
+		})
+	})
+
+	Context("keyring", func() {
+		BeforeEach(func() {
+			keyring.MockInit()
+		})
+
+		It("should migrate the plain token file", func() {
+			Ω(types.SaveTokens(token)).ShouldNot(HaveOccurred())
+
+			store, err := types.NewTokenStore(types.TokenStoreKeyring)
+			Ω(err).ShouldNot(HaveOccurred())
+			loaded, err := store.Load()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(loaded.Get("me@example.com").Token.RefreshToken).Should(Equal("refresh"))
+			Ω(filepath.Join(tokenDir, types.TokenFileName)).ShouldNot(BeAnExistingFile())
+
+			Ω(store.Delete()).ShouldNot(HaveOccurred())
+			loaded, err = store.Load()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(loaded).Should(BeNil())
+		})
+	})
+
+	Context("file", func() {
+		It("should read a token in the legacy single account format", func() {
+			Ω(os.MkdirAll(tokenDir, 0o700)).ShouldNot(HaveOccurred())
+			Ω(os.WriteFile(filepath.Join(tokenDir, types.TokenFileName), []byte(`token:
+  accesstoken: access
+  refreshtoken: refresh
+`), 0o600)).ShouldNot(HaveOccurred())
+
+			loaded, err := types.LoadTokens()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(loaded.Get("").Token.RefreshToken).Should(Equal("refresh"))
+		})
+	})
+
+	It("should fail for an unknown store", func() {
+		_, err := types.NewTokenStore("unknown")
+		Ω(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,47 @@
+package types
+
+import "time"
+
+const (
+	// DefaultKeepaliveSeconds the default interval of the websocket keepalive pings.
+	DefaultKeepaliveSeconds = 10
+)
+
+// TunnelSettings configures the connections of the tunnels.
+type TunnelSettings struct {
+	// KeepaliveSeconds the interval of the websocket pings, a connection without pong for 3 intervals is closed.
+	// 0 uses the default of 10 seconds, a negative value disables the keepalive.
+	KeepaliveSeconds int `yaml:"keepaliveSeconds,omitempty"`
+	// IdleTimeoutSeconds closes connections without traffic for the duration, 0 disables the idle timeout.
+	IdleTimeoutSeconds int `yaml:"idleTimeoutSeconds,omitempty"`
+	// MaxConnections the maximum number of concurrent connections per tunnel, 0 is unlimited.
+	MaxConnections int `yaml:"maxConnections,omitempty"`
+}
+
+// KeepaliveInterval returns the interval of the websocket keepalive pings, 0 if disabled.
+func (c *Config) KeepaliveInterval() time.Duration {
+	seconds := DefaultKeepaliveSeconds
+	if c.Tunnel != nil && c.Tunnel.KeepaliveSeconds != 0 {
+		seconds = c.Tunnel.KeepaliveSeconds
+	}
+	if seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// IdleTimeout returns the idle timeout of the tunnel connections, 0 if disabled.
+func (c *Config) IdleTimeout() time.Duration {
+	if c.Tunnel == nil || c.Tunnel.IdleTimeoutSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(c.Tunnel.IdleTimeoutSeconds) * time.Second
+}
+
+// MaxConnections returns the maximum number of concurrent connections per tunnel, 0 if unlimited.
+func (c *Config) MaxConnections() int {
+	if c.Tunnel == nil || c.Tunnel.MaxConnections < 0 {
+		return 0
+	}
+	return c.Tunnel.MaxConnections
+}
@@ -2,6 +2,11 @@ package types
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/bakito/gws/internal/env"
 )
 
 type Context struct {
@@ -10,11 +15,24 @@ type Context struct {
 	User           string `yaml:"user"`
 	PrivateKeyFile string `yaml:"privateKeyFile"`
 	KnownHostsFile string `yaml:"knownHostsFile"`
+	// KnownHostsAlias an optional host name added to the known_hosts entries of the tunnel, e.g. for HostKeyAlias.
+	KnownHostsAlias string `yaml:"knownHostsAlias,omitempty"`
+	TimeoutSeconds  int    `yaml:"timeoutSeconds,omitempty"`
+	// Listen the local address of the tunnel: "<host>:<port>", "<host>" to bind the port of the context
+	// or "unix:<path>" for a unix socket only accessible by the user. Defaults to 127.0.0.1 and the port.
+	Listen string `yaml:"listen,omitempty"`
 
-	GCloud *GCloud `yaml:"gcloud"`
+	GCloud      *GCloud          `yaml:"gcloud"`
+	Account     string           `yaml:"account,omitempty"`
+	Auth        *Auth            `yaml:"auth,omitempty"`
+	Workstation *WorkstationSpec `yaml:"workstation,omitempty"`
 
 	Dirs  []Dir  `yaml:"dirs,omitempty"`
 	Files []File `yaml:"files,omitempty"`
+
+	ReadinessCommands []string `yaml:"readinessCommands,omitempty"`
+
+	Forwards []Forward `yaml:"forwards,omitempty"`
 }
 
 type GCloud struct {
@@ -25,6 +43,142 @@ type GCloud struct {
 	Name    string `yaml:"name"`
 }
 
+// Location returns the resource name of the project location of the workstation.
+func (g *GCloud) Location() string {
+	return fmt.Sprintf("projects/%s/locations/%s", g.Project, g.Region)
+}
+
+// ConfigName returns the full resource name of the workstation config.
+func (g *GCloud) ConfigName() string {
+	return fmt.Sprintf("%s/workstationClusters/%s/workstationConfigs/%s", g.Location(), g.Cluster, g.Config)
+}
+
+// WorkstationName returns the full resource name of the workstation.
+func (g *GCloud) WorkstationName() string {
+	return fmt.Sprintf("%s/workstations/%s", g.ConfigName(), g.Name)
+}
+
+// AuthType the source of the credentials used to access the workstations API.
+type AuthType string
+
+const (
+	// AuthTypeOAuth uses the gws OAuth login (default).
+	AuthTypeOAuth AuthType = "oauth"
+	// AuthTypeADC uses the Application Default Credentials.
+	AuthTypeADC AuthType = "adc"
+	// AuthTypeServiceAccount uses a service account JSON key file.
+	AuthTypeServiceAccount AuthType = "serviceAccount"
+	// AuthTypeImpersonate impersonates a service account with the gws OAuth login.
+	AuthTypeImpersonate AuthType = "impersonate"
+)
+
+// Auth defines the credentials used to access the workstations API.
+type Auth struct {
+	Type AuthType `yaml:"type"`
+	// KeyFile the service account JSON key file used by type serviceAccount.
+	KeyFile string `yaml:"keyFile,omitempty"`
+	// ServiceAccount the email of the service account to impersonate used by type impersonate.
+	ServiceAccount string `yaml:"serviceAccount,omitempty"`
+	// Delegates the optional delegation chain used by type impersonate.
+	Delegates []string `yaml:"delegates,omitempty"`
+}
+
+// AuthType returns the auth type of the context.
+func (c Context) AuthType() AuthType {
+	if c.Auth == nil || c.Auth.Type == "" {
+		return AuthTypeOAuth
+	}
+	return c.Auth.Type
+}
+
+// WorkstationSpec defines the optional properties of a workstation created with gws.
+type WorkstationSpec struct {
+	DisplayName string            `yaml:"displayName,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+	// Env the env variables passed to the entrypoint of the workstation container.
+	Env map[string]string `yaml:"env,omitempty"`
+}
+
+// Forward defines a local port forwarded to a port of the workstation.
+type Forward struct {
+	// LocalPort the local port, defaults to the remote port.
+	LocalPort  int `yaml:"localPort,omitempty"`
+	RemotePort int `yaml:"remotePort"`
+}
+
+// ParseForward parses a forward in the form "<port>" or "<local port>:<remote port>".
+func ParseForward(s string) (Forward, error) {
+	local, remote, found := strings.Cut(s, ":")
+	if !found {
+		remote = local
+	}
+	lp, err := strconv.Atoi(local)
+	if err != nil || lp < 1 || lp > 65535 {
+		return Forward{}, fmt.Errorf("invalid local port in forward %q", s)
+	}
+	rp, err := strconv.Atoi(remote)
+	if err != nil || rp < 1 || rp > 65535 {
+		return Forward{}, fmt.Errorf("invalid remote port in forward %q", s)
+	}
+	return Forward{LocalPort: lp, RemotePort: rp}, nil
+}
+
+// Validate returns an error if a port of the forward is out of range, a local port 0 is the remote port.
+func (f Forward) Validate() error {
+	if f.RemotePort < 1 || f.RemotePort > 65535 {
+		return fmt.Errorf("invalid remote port %d in forward", f.RemotePort)
+	}
+	if f.LocalPort < 0 || f.LocalPort > 65535 {
+		return fmt.Errorf("invalid local port %d in forward", f.LocalPort)
+	}
+	return nil
+}
+
+// Local returns the local port of the forward.
+func (f Forward) Local() int {
+	if f.LocalPort == 0 {
+		return f.RemotePort
+	}
+	return f.LocalPort
+}
+
+func (f Forward) String() string {
+	return fmt.Sprintf("%d:%d", f.Local(), f.RemotePort)
+}
+
+// ListenUnixPrefix the prefix of a unix socket listen address.
+const ListenUnixPrefix = "unix:"
+
+// ListenAddress returns the network and the local address of the tunnel of the context.
+// If port is not 0, it overrides the port of the context and a unix socket is replaced by a loopback port.
+func (c Context) ListenAddress(port int) (network, address string) {
+	if path, ok := strings.CutPrefix(c.Listen, ListenUnixPrefix); ok && port == 0 {
+		return "unix", env.ExpandEnv(path)
+	}
+
+	host, p := "127.0.0.1", strconv.Itoa(c.Port)
+	if c.Listen != "" && !strings.HasPrefix(c.Listen, ListenUnixPrefix) {
+		if h, lp, err := net.SplitHostPort(c.Listen); err == nil {
+			host, p = h, lp
+		} else {
+			host = c.Listen
+		}
+	}
+	if port != 0 {
+		p = strconv.Itoa(port)
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return "tcp", net.JoinHostPort(host, p)
+}
+
+// ListensOnUnixSocket returns true if the tunnel of the context listens on a unix socket.
+func (c Context) ListensOnUnixSocket() bool {
+	return strings.HasPrefix(c.Listen, ListenUnixPrefix)
+}
+
 func (c Context) HostAddr() string {
 	return fmt.Sprintf("%s:%d", c.Host, c.Port)
 }
@@ -0,0 +1,59 @@
+package types_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/bakito/gws/internal/types"
+)
+
+var _ = Describe("Types", func() {
+	DescribeTable("ParseForward",
+		func(in string, local, remote int, valid bool) {
+			f, err := types.ParseForward(in)
+			if !valid {
+				Ω(err).Should(HaveOccurred())
+				return
+			}
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(f.Local()).Should(Equal(local))
+			Ω(f.RemotePort).Should(Equal(remote))
+		},
+		Entry("same port", "5432", 5432, 5432, true),
+		Entry("local and remote port", "8080:3000", 8080, 3000, true),
+		Entry("invalid port", "abc", 0, 0, false),
+		Entry("invalid remote port", "8080:", 0, 0, false),
+		Entry("port out of range", "70000", 0, 0, false),
+	)
+
+	DescribeTable("Forward.Validate",
+		func(f types.Forward, valid bool) {
+			if valid {
+				Ω(f.Validate()).ShouldNot(HaveOccurred())
+			} else {
+				Ω(f.Validate()).Should(HaveOccurred())
+			}
+		},
+		Entry("remote port only", types.Forward{RemotePort: 5432}, true),
+		Entry("local and remote port", types.Forward{LocalPort: 8080, RemotePort: 3000}, true),
+		Entry("missing remote port", types.Forward{LocalPort: 8080}, false),
+		Entry("remote port out of range", types.Forward{RemotePort: 70000}, false),
+		Entry("negative local port", types.Forward{LocalPort: -1, RemotePort: 3000}, false),
+	)
+
+	DescribeTable("ListenAddress",
+		func(listen string, port int, network, address string) {
+			c := types.Context{Port: 2222, Listen: listen}
+			n, a := c.ListenAddress(port)
+			Ω(n).Should(Equal(network))
+			Ω(a).Should(Equal(address))
+		},
+		Entry("default", "", 0, "tcp", "127.0.0.1:2222"),
+		Entry("port override", "", 3333, "tcp", "127.0.0.1:3333"),
+		Entry("bind host", "0.0.0.0", 0, "tcp", "0.0.0.0:2222"),
+		Entry("host and port", "192.168.1.2:4444", 0, "tcp", "192.168.1.2:4444"),
+		Entry("ipv6 host", "::1", 0, "tcp", "[::1]:2222"),
+		Entry("unix socket", "unix:/tmp/gws.sock", 0, "unix", "/tmp/gws.sock"),
+		Entry("unix socket with port override", "unix:/tmp/gws.sock", 3333, "tcp", "127.0.0.1:3333"),
+	)
+})